@@ -1,76 +1,280 @@
 package applier
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
-	"regexp"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gitops-tools/image-updater/pkg/config"
 	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/keylock"
+	"github.com/gitops-tools/image-updater/pkg/reference"
+	"github.com/gitops-tools/image-updater/pkg/retry"
+	"github.com/gitops-tools/image-updater/pkg/tagpolicy"
 	"github.com/gitops-tools/pkg/client"
 	"github.com/gitops-tools/pkg/updater"
 	"github.com/go-logr/logr"
+	"github.com/jenkins-x/go-scm/scm"
+	"sigs.k8s.io/yaml"
 )
 
 var timeSeed = rand.New(rand.NewSource(time.Now().UnixNano()))
 
+// DefaultConflictRetries is the default number of times that an update is
+// retried when the file was changed after it was fetched.
+const DefaultConflictRetries = 3
+
 // New creates and returns a new Applier.
 func New(l logr.Logger, c client.GitClient, cfgs *config.RepoConfiguration, opts ...updater.UpdaterFunc) *Applier {
-	return &Applier{configs: cfgs, log: l, updater: updater.New(l, c, opts...)}
+	a := &Applier{
+		log:             l,
+		gitClient:       c,
+		updater:         updater.New(l, branchRecorder{GitClient: c}, opts...),
+		progress:        newProgress(),
+		locks:           keylock.New(),
+		conflictRetries: DefaultConflictRetries,
+		backoff:         retry.DefaultBackoff,
+	}
+	a.configs.Store(cfgs)
+	return a
 }
 
 // Applier can update a Git repo with an updated version of a file based on a
 // RepositoryPushHook.
+//
+// Updates to the same file from the same source branch are serialized, so
+// that concurrent updates don't commit on top of each other.
+//
+// Transient errors from the Git service are retried with backoff, and the
+// errors returned are classified, use retry.IsTransient to check whether
+// the update should be retried later.
+//
+// The configuration can be replaced with SetConfig while updates are being
+// applied, updates that are in progress continue with the configuration that
+// they found.
 type Applier struct {
-	configs *config.RepoConfiguration
-	log     logr.Logger
-	updater *updater.Updater
+	configs         atomic.Pointer[config.RepoConfiguration]
+	log             logr.Logger
+	gitClient       client.GitClient
+	updater         *updater.Updater
+	progress        *progress
+	locks           *keylock.Locker
+	conflictRetries int
+	backoff         retry.Backoff
+}
+
+// SetConflictRetries sets the number of times that an update is retried when
+// the file was changed in the Git service after it was fetched.
+func (u *Applier) SetConflictRetries(n int) {
+	u.conflictRetries = n
 }
 
-// UpdateFromHook takes the incoming hook and triggers an update based on the
-// configuration for the repo in the hook (if one matches).
+// SetConfig replaces the configuration used to find the repositories to update.
+func (u *Applier) SetConfig(cfgs *config.RepoConfiguration) {
+	u.configs.Store(cfgs)
+}
+
+// SetBackoff configures how transient errors from the Git service are
+// retried.
+func (u *Applier) SetBackoff(b retry.Backoff) {
+	u.backoff = b
+}
+
+// UpdateFromHook takes the incoming hook and triggers an update for each of
+// the configurations for the repo in the hook (if any match).
+//
+// All the matching configurations are updated, if any of them fail, an
+// UpdateError is returned with the results for each configuration.
 func (u *Applier) UpdateFromHook(ctx context.Context, h hooks.PushEvent) error {
-	cfg := u.configs.Find(h.EventRepository())
-	if cfg == nil {
+	cfgs, err := u.FindConfigs(h)
+	if err != nil {
+		return err
+	}
+	results := make([]Result, len(cfgs))
+	for i, cfg := range cfgs {
+		results[i] = Result{Config: cfg, Err: u.updateFromHook(ctx, cfg, h)}
+	}
+	return Aggregate(results)
+}
+
+func (u *Applier) updateFromHook(ctx context.Context, cfg *config.Repository, h hooks.PushEvent) error {
+	newURL, err := ImageURL(cfg, h)
+	if err != nil {
+		return err
+	}
+	return u.UpdateRepository(ctx, cfg, newURL)
+}
+
+// ImageURL returns the image to update the repository with, if the
+// configuration pins digests, the digest from the hook is added to the
+// pushed image.
+//
+// An error is returned if the configuration pins digests and the hook
+// doesn't provide a valid digest.
+func ImageURL(cfg *config.Repository, h hooks.PushEvent) (string, error) {
+	switch cfg.PinDigest {
+	case "":
+		return h.PushedImageURL(), nil
+	case config.PinDigestWithTag, config.PinDigestOnly:
+	default:
+		return "", fmt.Errorf("invalid pinDigest %q for repository %s, must be %q or %q", cfg.PinDigest, cfg.Name, config.PinDigestWithTag, config.PinDigestOnly)
+	}
+
+	ref, err := reference.Parse(h.PushedImageURL())
+	if err != nil {
+		return "", fmt.Errorf("failed to parse pushed image %q: %w", h.PushedImageURL(), err)
+	}
+	digest, err := h.ImageDigest()
+	if err != nil {
+		return "", fmt.Errorf("repository %s pins digests, but the hook for %s has an invalid digest: %w", cfg.Name, h.PushedImageURL(), err)
+	}
+	if digest == "" {
+		return "", fmt.Errorf("repository %s pins digests, but the hook for %s has no digest", cfg.Name, h.PushedImageURL())
+	}
+	if err := reference.ValidateDigest(digest); err != nil {
+		return "", fmt.Errorf("repository %s pins digests, but the hook for %s has an invalid digest: %w", cfg.Name, h.PushedImageURL(), err)
+	}
+	ref.Digest = digest
+	if cfg.PinDigest == config.PinDigestOnly {
+		ref.Tag = ""
+	}
+	return ref.String(), nil
+}
+
+// FindConfigs returns the configurations for the repo in the hook, if a
+// configuration has a TagMatch, the tag in the hook must also match it.
+//
+// If no configuration matches, an empty slice is returned.
+func (u *Applier) FindConfigs(h hooks.PushEvent) ([]*config.Repository, error) {
+	cfgs, err := u.configs.Load().Find(h.EventRepository())
+	if err != nil {
+		return nil, err
+	}
+	if len(cfgs) == 0 {
 		u.log.Info("failed to find repo", "name", h.EventRepository())
-		return nil
+		return nil, nil
 	}
-	if cfg.TagMatch != "" {
-		re, err := regexp.Compile(cfg.TagMatch)
+	var matched []*config.Repository
+	for _, cfg := range cfgs {
+		ok, err := u.matches(cfg, h)
 		if err != nil {
-			return fmt.Errorf("failed to compile TagMatch regular expression: %s", err)
+			return nil, err
+		}
+		if ok {
+			u.log.Info("found repo", "name", h.EventRepository(), "sourceRepo", cfg.SourceRepo, "filePaths", cfg.FilePaths(), "newURL", h.PushedImageURL())
+			matched = append(matched, cfg)
 		}
+	}
+	return matched, nil
+}
+
+func (u *Applier) matches(cfg *config.Repository, h hooks.PushEvent) (bool, error) {
+	re, err := cfg.TagMatchRegexp()
+	if err != nil {
+		return false, err
+	}
+	if re != nil {
 		if !re.MatchString(h.EventTag()) {
-			u.log.Info("failed to match tag", "tag", h.EventTag(), "tagMatch", cfg.TagMatch)
-			return nil
+			u.log.Info("failed to match tag", "tag", h.EventTag(), "tagMatch", cfg.TagMatch, "sourceRepo", cfg.SourceRepo)
+			return false, nil
+		}
+	}
+	policy, err := tagpolicy.ForRepository(cfg)
+	if err != nil {
+		return false, err
+	}
+	if policy != nil {
+		if err := policy.Allows(h.EventTag()); err != nil {
+			u.log.Info("tag not allowed by tagPolicy", "tag", h.EventTag(), "reason", err.Error(), "sourceRepo", cfg.SourceRepo)
+			return false, nil
 		}
 	}
-	u.log.Info("found repo", "name", h.EventRepository(), "newURL", h.PushedImageURL())
-	return u.UpdateRepository(ctx, cfg, h.PushedImageURL())
+	return true, nil
 }
 
-// UpdateRepository does the job of fetching the existing file, updating it, and
-// then optionally creating a PR.
+// UpdateRepository does the job of fetching the existing files, updating them,
+// and then optionally creating a PR.
+//
+// All the files are fetched and updated before any are committed, so that an
+// update that can't be applied to one of the files fails before a branch is
+// created.
+//
+// The Git service API commits a single file at a time, so each file is
+// committed separately, but all the files are updated in the same branch,
+// and a single PR is created. If committing fails part-way, the branch is
+// recorded, and when the update is retried, it continues in the same branch.
+//
+// Concurrent updates of the same files with the same image, e.g. when a hook
+// is redelivered while it's being processed, are applied one at a time, so
+// that the update is only applied once.
 func (u *Applier) UpdateRepository(ctx context.Context, cfg *config.Repository, newURL string) error {
-	ci := updater.CommitInput{
-		Repo:               cfg.SourceRepo,
-		Filename:           cfg.FilePath,
-		Branch:             cfg.SourceBranch,
-		BranchGenerateName: cfg.BranchGenerateName,
-		CommitMessage:      "Automatic update because an image was updated",
+	targets := cfg.AllTargets()
+	if len(targets) == 0 {
+		return fmt.Errorf("no files to update for repository %s", cfg.Name)
+	}
+	policy, err := tagpolicy.ForRepository(cfg)
+	if err != nil {
+		return err
 	}
 
-	newBranch, err := u.updater.ApplyUpdateToFile(ctx, ci, updater.UpdateYAML(cfg.UpdateKey, newURL))
+	key := progressKey(cfg, newURL)
+	defer u.progress.lock(key)()
+	defer u.lockFiles(cfg)()
+	previous := u.progress.get(key)
+	if previous.done {
+		u.log.Info("skipping update, already applied", "sourceRepo", cfg.SourceRepo, "filePaths", cfg.FilePaths(), "image", newURL, "branch", previous.branch)
+		return nil
+	}
+	newBranch := previous.branch
+	base := cfg.SourceBranch
+	if newBranch != "" {
+		u.log.Info("continuing update in existing branch", "sourceRepo", cfg.SourceRepo, "branch", newBranch)
+		base = newBranch
+	}
+	filePaths, err := u.changedFiles(ctx, cfg.SourceRepo, base, targets, cfg.FilePaths(), newURL, policy)
 	if err != nil {
 		u.log.Error(err, "failed to get file from repo")
 		return err
 	}
+
+	for _, filePath := range filePaths {
+		ci := inBranch(updater.CommitInput{
+			Repo:               cfg.SourceRepo,
+			Filename:           filePath,
+			Branch:             cfg.SourceBranch,
+			BranchGenerateName: cfg.BranchGenerateName,
+			CommitMessage:      "Automatic update because an image was updated",
+		}, newBranch)
+
+		branch, err := u.applyUpdate(ctx, ci, contentUpdater(targets, filePath, newURL, policy))
+		if branch != "" {
+			newBranch = branch
+		}
+		if errors.Is(err, tagpolicy.ErrDowngrade) {
+			u.log.Info("skipping update", "filename", filePath, "reason", err.Error())
+			continue
+		}
+		if err != nil {
+			u.log.Error(err, "failed to update file in repo", "filename", filePath, "branch", newBranch)
+			u.recordBranch(key, cfg, newBranch)
+			return err
+		}
+	}
+	if newBranch == "" {
+		return nil
+	}
 	u.log.Info("updated branch with image", "image", newURL, "branch", newBranch)
 
 	// If we modified the original branch...
 	if newBranch == cfg.SourceBranch {
+		u.progress.set(key, record{branch: newBranch, done: true})
 		return nil
 	}
 
@@ -82,10 +286,167 @@ func (u *Applier) UpdateRepository(ctx context.Context, cfg *config.Repository,
 		SourceBranch: cfg.SourceBranch,
 	}
 
-	pr, err := u.updater.CreatePR(ctx, pullRequestInput)
+	var pr *scm.PullRequest
+	err = u.backoff.Do(ctx, func(ctx context.Context) error {
+		var err error
+		pr, err = u.updater.CreatePR(ctx, pullRequestInput)
+		return err
+	})
 	if err != nil {
+		u.recordBranch(key, cfg, newBranch)
 		return fmt.Errorf("failed to create pull request in repo %s: %w", cfg.SourceRepo, err)
 	}
+	u.progress.set(key, record{branch: newBranch, done: true})
 	u.log.Info("created PullRequest", "link", pr.Link)
 	return nil
 }
+
+// lockFiles blocks until the locks for the files that the repository
+// updates are acquired, and returns a function that releases them.
+//
+// The locks are for the files in the source branch, even when the update is
+// committed to a generated branch, so that updates of the same file from the
+// same source branch are applied one at a time. The locks are acquired in
+// order, so that updates of overlapping files don't deadlock.
+func (u *Applier) lockFiles(cfg *config.Repository) func() {
+	var keys []string
+	for _, filePath := range cfg.FilePaths() {
+		keys = append(keys, strings.Join([]string{cfg.SourceRepo, cfg.SourceBranch, filePath}, ":"))
+	}
+	sort.Strings(keys)
+	unlocks := make([]func(), len(keys))
+	for i, key := range keys {
+		unlocks[i] = u.locks.Lock(key)
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+// recordBranch records the branch that an update created, if it's not the
+// source branch, so that the update continues in it when it's retried.
+func (u *Applier) recordBranch(key string, cfg *config.Repository, branch string) {
+	if branch == "" || branch == cfg.SourceBranch {
+		return
+	}
+	u.log.Info("recorded incomplete update", "sourceRepo", cfg.SourceRepo, "branch", branch)
+	u.progress.set(key, record{branch: branch})
+}
+
+// changedFiles fetches the files from the branch, and applies the update to
+// them, and returns the files that the update changes.
+//
+// Files that already have the new image, e.g. because the hook was
+// redelivered, and files that the tag policy doesn't allow to be updated are
+// skipped.
+func (u *Applier) changedFiles(ctx context.Context, repo, branch string, targets []config.Target, filePaths []string, newURL string, policy *tagpolicy.Policy) ([]string, error) {
+	var changed []string
+	for _, filePath := range filePaths {
+		var current *scm.Content
+		err := u.backoff.Do(ctx, func(ctx context.Context) error {
+			var err error
+			current, err = u.gitClient.GetFile(ctx, repo, branch, filePath)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		updated, err := contentUpdater(targets, filePath, newURL, policy)(current.Data)
+		if errors.Is(err, tagpolicy.ErrDowngrade) {
+			u.log.Info("skipping update", "filename", filePath, "reason", err.Error())
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if unchanged(current.Data, updated) {
+			u.log.Info("skipping update, file is up to date", "filename", filePath, "image", newURL)
+			continue
+		}
+		changed = append(changed, filePath)
+	}
+	return changed, nil
+}
+
+// unchanged returns true if the updated file has the same content as the
+// current file, updating the file can change the formatting, even if the
+// values are the same.
+func unchanged(current, updated []byte) bool {
+	if bytes.Equal(current, updated) {
+		return true
+	}
+	var c, u interface{}
+	if err := yaml.Unmarshal(current, &c); err != nil {
+		return false
+	}
+	if err := yaml.Unmarshal(updated, &u); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(c, u)
+}
+
+// contentUpdater returns a ContentUpdater that updates all the keys in the
+// file with the new image.
+func contentUpdater(targets []config.Target, filePath, newURL string, policy *tagpolicy.Policy) updater.ContentUpdater {
+	var updaters []updater.ContentUpdater
+	for _, t := range targets {
+		if t.FilePath != filePath {
+			continue
+		}
+		f := updater.UpdateYAML(t.UpdateKey, newURL)
+		if policy != nil {
+			f = policy.ContentUpdater(t.UpdateKey, newURL, f)
+		}
+		updaters = append(updaters, f)
+	}
+	return func(b []byte) ([]byte, error) {
+		var err error
+		for _, f := range updaters {
+			if b, err = f(b); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+}
+
+// applyUpdate updates the file, if the file was changed after it was
+// fetched, it is fetched again and the update is reapplied.
+//
+// If a branch is created, and the commit fails, the commit is retried in the
+// same branch, and if the commit can't be made, the branch is returned with
+// the error.
+func (u *Applier) applyUpdate(ctx context.Context, ci updater.CommitInput, f updater.ContentUpdater) (string, error) {
+	ctx, created := withCreatedBranch(ctx)
+	for attempt := 0; ; attempt++ {
+		var newBranch string
+		err := u.backoff.Do(ctx, func(ctx context.Context) error {
+			var err error
+			newBranch, err = u.updater.ApplyUpdateToFile(ctx, inBranch(ci, *created), f)
+			return err
+		})
+		if err == nil {
+			return newBranch, nil
+		}
+		if !retry.IsConflict(err) {
+			return *created, err
+		}
+		if attempt >= u.conflictRetries {
+			// Redelivering the update later is likely to succeed.
+			return *created, &retry.Error{Err: err, Transient: true}
+		}
+		u.log.Info("file changed while updating, retrying", "repo", ci.Repo, "filename", ci.Filename, "attempt", attempt+1, "err", err.Error())
+	}
+}
+
+// inBranch returns the input to commit to the branch, if a branch has been
+// created, rather than generating another branch.
+func inBranch(ci updater.CommitInput, branch string) updater.CommitInput {
+	if branch != "" {
+		ci.Branch = branch
+		ci.BranchGenerateName = ""
+	}
+	return ci
+}
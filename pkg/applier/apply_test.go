@@ -4,10 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gitops-tools/image-updater/pkg/config"
 	"github.com/gitops-tools/image-updater/pkg/hooks/quay"
+	"github.com/gitops-tools/image-updater/pkg/retry"
+	"github.com/gitops-tools/image-updater/test"
 	"github.com/gitops-tools/pkg/client/mock"
 	"github.com/gitops-tools/pkg/updater"
 	"github.com/go-logr/zapr"
@@ -139,9 +144,12 @@ func TestUpdaterWithMissingFile(t *testing.T) {
 
 	err := applier.UpdateFromHook(context.Background(), hook)
 
-	if err != testErr {
+	if !errors.Is(err, testErr) {
 		t.Fatalf("got %s, want %s", err, testErr)
 	}
+	if retry.IsTransient(err) {
+		t.Fatalf("got a transient error %s, want a permanent error", err)
+	}
 	updated := m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")
 	if s := string(updated); s != "" {
 		t.Fatalf("update failed, got %#v, want %#v", s, "")
@@ -162,8 +170,8 @@ func TestUpdaterWithBranchCreationFailure(t *testing.T) {
 
 	err := applier.UpdateFromHook(context.Background(), hook)
 
-	if err.Error() != "failed to create branch: can't create branch" {
-		t.Fatalf("got %s, want %s", err, "failed to create branch: can't create branch")
+	if err.Error() != "failed to update 1 of 1 targets: testorg/testrepo:environments/test/services/service-a/test.yaml: failed to create branch: can't create branch" {
+		t.Fatalf("got %s, want %s", err, "failed to update 1 of 1 targets: testorg/testrepo:environments/test/services/service-a/test.yaml: failed to create branch: can't create branch")
 	}
 	updated := m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")
 	if s := string(updated); s != "" {
@@ -185,8 +193,8 @@ func TestUpdaterWithUpdateFileFailure(t *testing.T) {
 
 	err := applier.UpdateFromHook(context.Background(), hook)
 
-	if err.Error() != "failed to update file: can't update file" {
-		t.Fatalf("got %s, want %s", err, "failed to update file: can't update file")
+	if err.Error() != "failed to update 1 of 1 targets: testorg/testrepo:environments/test/services/service-a/test.yaml: failed to update file: can't update file" {
+		t.Fatalf("got %s, want %s", err, "failed to update 1 of 1 targets: testorg/testrepo:environments/test/services/service-a/test.yaml: failed to update file: can't update file")
 	}
 	updated := m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")
 	if s := string(updated); s != "" {
@@ -201,6 +209,567 @@ func TestUpdaterWithUpdateFileFailure(t *testing.T) {
 	})
 }
 
+func TestUpdaterWithConflicts(t *testing.T) {
+	conflictTests := []struct {
+		name      string
+		retries   int
+		conflicts int
+		wantErr   string
+		wantCalls int
+	}{
+		{"no conflicts", DefaultConflictRetries, 0, "", 1},
+		{"conflict then success", DefaultConflictRetries, 2, "", 3},
+		{"too many conflicts", DefaultConflictRetries, 4, "failed to update file: Conflict", 4},
+		{"no retries", 0, 1, "failed to update file: Conflict", 1},
+	}
+
+	for _, tt := range conflictTests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+			m := mock.New(t)
+			m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+			m.AddBranchHead(testGitHubRepo, "master", testSHA)
+			c := &conflictingClient{MockClient: m, conflicts: tt.conflicts}
+			configs := createConfigs()
+			configs.Repositories[0].BranchGenerateName = ""
+			logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+			applier := New(logger, c, configs)
+			applier.SetConflictRetries(tt.retries)
+
+			err := applier.UpdateFromHook(context.Background(), createHook())
+
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if c.calls != tt.wantCalls {
+				t.Fatalf("got %d calls to UpdateFile, want %d", c.calls, tt.wantCalls)
+			}
+			if tt.wantErr != "" {
+				if !retry.IsTransient(err) {
+					t.Fatalf("got a permanent error %s, want a transient error", err)
+				}
+				return
+			}
+			want := "test:\n  image: quay.io/testorg/repo:production\n"
+			if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "master")); s != want {
+				t.Fatalf("update failed, got %#v, want %#v", s, want)
+			}
+		})
+	}
+}
+
+func TestUpdaterWithConflictsInNewBranch(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	// The mock client doesn't copy files to new branches.
+	m.AddFileContents(testGitHubRepo, testFilePath, "test-branch-a", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "test-branch-a", testSHA)
+	c := &conflictingClient{MockClient: m, conflicts: 2}
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	applier := New(logger, c, createConfigs(), updater.NameGenerator(stubNameGenerator{name: "a"}))
+
+	err := applier.UpdateFromHook(context.Background(), createHook())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.branches != 1 {
+		t.Fatalf("got %d branches created, want 1", c.branches)
+	}
+	want := "test:\n  image: quay.io/testorg/repo:production\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+	m.AssertPullRequestCreated(testGitHubRepo, &scm.PullRequestInput{
+		Title: "Automated image update",
+		Body:  fmt.Sprintf("Automated update from %q", testQuayRepo),
+		Head:  "test-branch-a",
+		Base:  "master",
+	})
+}
+
+func TestUpdaterWithTransientErrors(t *testing.T) {
+	transientTests := []struct {
+		name          string
+		failures      int
+		wantErr       string
+		wantTransient bool
+		wantCalls     int
+	}{
+		{"recovers", 2, "", false, 3},
+		{"fails", 5, "failed to update file: Bad Gateway", true, 3},
+	}
+
+	for _, tt := range transientTests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+			m := mock.New(t)
+			m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+			m.AddBranchHead(testGitHubRepo, "master", testSHA)
+			// The mock client doesn't copy files to new branches.
+			m.AddFileContents(testGitHubRepo, testFilePath, "test-branch-a", []byte("test:\n  image: old-image\n"))
+			m.AddBranchHead(testGitHubRepo, "test-branch-a", testSHA)
+			c := &conflictingClient{MockClient: m, conflicts: tt.failures, err: errors.New(http.StatusText(http.StatusBadGateway))}
+			logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+			applier := New(logger, c, createConfigs(), updater.NameGenerator(stubNameGenerator{name: "a"}))
+			applier.SetBackoff(retry.Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Second})
+
+			err := applier.UpdateFromHook(context.Background(), createHook())
+
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if retry.IsTransient(err) != tt.wantTransient {
+				t.Fatalf("got transient %v, want %v", retry.IsTransient(err), tt.wantTransient)
+			}
+			if c.calls != tt.wantCalls {
+				t.Fatalf("got %d calls to UpdateFile, want %d", c.calls, tt.wantCalls)
+			}
+			if c.branches != 1 {
+				t.Fatalf("got %d branches created, want 1", c.branches)
+			}
+			if tt.wantErr != "" {
+				return
+			}
+			want := "test:\n  image: quay.io/testorg/repo:production\n"
+			if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")); s != want {
+				t.Fatalf("update failed, got %#v, want %#v", s, want)
+			}
+		})
+	}
+}
+
+func TestUpdaterWithTagPolicy(t *testing.T) {
+	policyTests := []struct {
+		name       string
+		policy     *config.TagPolicy
+		current    string
+		tag        string
+		wantUpdate bool
+	}{
+		{"allowed tag", &config.TagPolicy{Semver: ">=1.2 <2.0"}, "old-image", "v1.2.0", true},
+		{"disallowed tag", &config.TagPolicy{Semver: ">=1.2 <2.0"}, "old-image", "v2.0.0", false},
+		{"prerelease", &config.TagPolicy{Semver: ">=1.2 <2.0"}, "old-image", "v1.3.0-rc1", false},
+		{"upgrade", &config.TagPolicy{NeverDowngrade: true}, "quay.io/testorg/repo:v1.2.0", "v1.2.1", true},
+		{"downgrade", &config.TagPolicy{NeverDowngrade: true}, "quay.io/testorg/repo:v1.2.0", "v1.1.9", false},
+	}
+
+	for _, tt := range policyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+			m := mock.New(t)
+			m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: "+tt.current+"\n"))
+			m.AddBranchHead(testGitHubRepo, "master", testSHA)
+			configs := createConfigs()
+			configs.Repositories[0].TagPolicy = tt.policy
+			applier := makeApplier(t, m, configs)
+			hook := createHook()
+			hook.UpdatedTags = []string{tt.tag}
+
+			err := applier.UpdateFromHook(context.Background(), hook)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.wantUpdate {
+				m.AssertNoInteractions()
+				return
+			}
+			want := "test:\n  image: quay.io/testorg/repo:" + tt.tag + "\n"
+			if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")); s != want {
+				t.Fatalf("update failed, got %#v, want %#v", s, want)
+			}
+		})
+	}
+}
+
+func TestUpdaterWithMultipleTargets(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	prodRepo := "testorg/prodrepo"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	m.AddFileContents(prodRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(prodRepo, "master", testSHA)
+	configs := createConfigs()
+	staging := *configs.Repositories[0]
+	staging.FilePath = "environments/staging/missing.yaml"
+	prod := *configs.Repositories[0]
+	prod.SourceRepo = prodRepo
+	configs.Repositories = append(configs.Repositories, &staging, &prod)
+	applier := makeApplier(t, m, configs)
+	hook := createHook()
+
+	err := applier.UpdateFromHook(context.Background(), hook)
+
+	want := "failed to update 1 of 3 targets: testorg/testrepo:environments/staging/missing.yaml: .*not found.* \\(updated: testorg/testrepo:environments/test/services/service-a/test.yaml, testorg/prodrepo:environments/test/services/service-a/test.yaml\\)"
+	if !test.MatchError(t, want, err) {
+		t.Fatalf("got error %v, want %s", err, want)
+	}
+	var updateErr *UpdateError
+	if !errors.As(err, &updateErr) {
+		t.Fatalf("got error %T, want an UpdateError", err)
+	}
+	if retry.IsTransient(err) {
+		t.Fatal("got a transient error, want a permanent error")
+	}
+	for _, repo := range []string{testGitHubRepo, prodRepo} {
+		updated := m.GetUpdatedContents(repo, testFilePath, "test-branch-a")
+		want := "test:\n  image: quay.io/testorg/repo:production\n"
+		if s := string(updated); s != want {
+			t.Fatalf("update of %s failed, got %#v, want %#v", repo, s, want)
+		}
+	}
+}
+
+func TestUpdaterWithMultipleTargetsRedelivered(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	stagingPath := "environments/staging/services/service-a/test.yaml"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	m.AddFileContents(testGitHubRepo, stagingPath, "master", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	c := &conflictingClient{MockClient: m, conflicts: 1, path: stagingPath, err: errors.New(http.StatusText(http.StatusBadGateway))}
+	configs := createConfigs()
+	staging := *configs.Repositories[0]
+	staging.FilePath = stagingPath
+	staging.BranchGenerateName = "staging-"
+	configs.Repositories = append(configs.Repositories, &staging)
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	applier := New(logger, c, configs, updater.NameGenerator(stubNameGenerator{name: "a"}))
+	applier.SetBackoff(retry.Backoff{Attempts: 1})
+	// The mock client doesn't copy files to new branches.
+	m.AddFileContents(testGitHubRepo, stagingPath, "staging-a", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "staging-a", testSHA)
+
+	err := applier.UpdateFromHook(context.Background(), createHook())
+	if !retry.IsTransient(err) {
+		t.Fatalf("got error %v, want a transient error", err)
+	}
+	if c.pullRequests != 1 {
+		t.Fatalf("got %d pull requests created, want 1", c.pullRequests)
+	}
+
+	// The hook is redelivered.
+	if err := applier.UpdateFromHook(context.Background(), createHook()); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.pullRequests != 2 {
+		t.Fatalf("got %d pull requests created, want 2", c.pullRequests)
+	}
+	if c.branches != 2 {
+		t.Fatalf("got %d branches created, want 2", c.branches)
+	}
+	for _, head := range []string{"test-branch-a", "staging-a"} {
+		m.AssertPullRequestCreated(testGitHubRepo, &scm.PullRequestInput{
+			Title: "Automated image update",
+			Body:  fmt.Sprintf("Automated update from %q", testQuayRepo),
+			Head:  head,
+			Base:  "master",
+		})
+	}
+}
+
+func TestUpdaterWithConcurrentRedelivery(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	// The mock client doesn't copy files to new branches.
+	m.AddFileContents(testGitHubRepo, testFilePath, "test-branch-a", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "test-branch-a", testSHA)
+	c := &conflictingClient{MockClient: m, delay: 10 * time.Millisecond}
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	applier := New(logger, c, createConfigs(), updater.NameGenerator(stubNameGenerator{name: "a"}))
+
+	start := make(chan struct{})
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			<-start
+			errs <- applier.UpdateFromHook(context.Background(), createHook())
+		}()
+	}
+	close(start)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if c.branches != 1 {
+		t.Fatalf("got %d branches created, want 1", c.branches)
+	}
+	if c.pullRequests != 1 {
+		t.Fatalf("got %d pull requests created, want 1", c.pullRequests)
+	}
+}
+
+func TestUpdaterLocksSourceBranch(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, "test-branch-a", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "test-branch-a", testSHA)
+	applier := makeApplier(t, m, createConfigs())
+	cfg := createConfigs().Repositories[0]
+	newURL := "quay.io/testorg/repo:production"
+	// The update failed part-way, and continues in the generated branch.
+	applier.progress.set(progressKey(cfg, newURL), record{branch: "test-branch-a"})
+
+	unlock := applier.locks.Lock(strings.Join([]string{testGitHubRepo, "master", testFilePath}, ":"))
+	done := make(chan error)
+	go func() {
+		done <- applier.UpdateRepository(context.Background(), cfg, newURL)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("update wasn't blocked by the lock for the source branch, got error %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	want := "test:\n  image: quay.io/testorg/repo:production\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+}
+
+func TestUpdaterWithUpToDateFile(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n    image:   quay.io/testorg/repo:production\n"))
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	applier := makeApplier(t, m, createConfigs())
+
+	err := applier.UpdateFromHook(context.Background(), createHook())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.AssertNoInteractions()
+}
+
+func TestUpdaterWithMultipleFiles(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	cronJobPath := "environments/test/services/service-a/cronjob.yaml"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n  initImage: old-image\n"))
+	m.AddFileContents(testGitHubRepo, cronJobPath, "master", []byte("spec:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	// The mock client doesn't copy files to new branches.
+	m.AddFileContents(testGitHubRepo, cronJobPath, "test-branch-a", []byte("spec:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "test-branch-a", testSHA)
+	configs := createConfigs()
+	configs.Repositories[0].Targets = []config.Target{
+		{FilePath: testFilePath, UpdateKey: "test.initImage"},
+		{FilePath: cronJobPath, UpdateKey: "spec.image"},
+	}
+	applier := makeApplier(t, m, configs)
+	hook := createHook()
+
+	err := applier.UpdateFromHook(context.Background(), hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "test:\n  image: quay.io/testorg/repo:production\n  initImage: quay.io/testorg/repo:production\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+	want = "spec:\n  image: quay.io/testorg/repo:production\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, cronJobPath, "test-branch-a")); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+	m.AssertBranchCreated(testGitHubRepo, "test-branch-a", testSHA)
+	m.AssertPullRequestCreated(testGitHubRepo, &scm.PullRequestInput{
+		Title: "Automated image update",
+		Body:  fmt.Sprintf("Automated update from %q", testQuayRepo),
+		Head:  "test-branch-a",
+		Base:  "master",
+	})
+}
+
+func TestUpdaterWithMultipleFilesAndMissingFile(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	configs := createConfigs()
+	configs.Repositories[0].Targets = []config.Target{
+		{FilePath: "environments/test/services/service-a/missing.yaml", UpdateKey: "spec.image"},
+	}
+	applier := makeApplier(t, m, configs)
+
+	err := applier.UpdateFromHook(context.Background(), createHook())
+	if !test.MatchError(t, "not found", err) {
+		t.Fatalf("got error %v, want not found", err)
+	}
+
+	m.AssertNoInteractions()
+}
+
+func TestUpdaterWithMultipleFilesFailingPartWay(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	cronJobPath := "environments/test/services/service-a/cronjob.yaml"
+	m := mock.New(t)
+	for _, branch := range []string{"master", "test-branch-a"} {
+		m.AddFileContents(testGitHubRepo, testFilePath, branch, []byte("test:\n  image: old-image\n"))
+		m.AddFileContents(testGitHubRepo, cronJobPath, branch, []byte("spec:\n  image: old-image\n"))
+		m.AddBranchHead(testGitHubRepo, branch, testSHA)
+	}
+	c := &conflictingClient{MockClient: m, conflicts: 1, path: cronJobPath, err: errors.New(http.StatusText(http.StatusBadGateway))}
+	configs := createConfigs()
+	configs.Repositories[0].Targets = []config.Target{
+		{FilePath: cronJobPath, UpdateKey: "spec.image"},
+	}
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	applier := New(logger, c, configs, updater.NameGenerator(stubNameGenerator{name: "a"}))
+	applier.SetBackoff(retry.Backoff{Attempts: 1})
+
+	err := applier.UpdateFromHook(context.Background(), createHook())
+	if !retry.IsTransient(err) {
+		t.Fatalf("got error %v, want a transient error", err)
+	}
+	m.AssertNoPullRequestsCreated()
+
+	// The hook is redelivered.
+	if err := applier.UpdateFromHook(context.Background(), createHook()); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.branches != 1 {
+		t.Fatalf("got %d branches created, want 1", c.branches)
+	}
+	want := "spec:\n  image: quay.io/testorg/repo:production\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, cronJobPath, "test-branch-a")); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+	m.AssertPullRequestCreated(testGitHubRepo, &scm.PullRequestInput{
+		Title: "Automated image update",
+		Body:  fmt.Sprintf("Automated update from %q", testQuayRepo),
+		Head:  "test-branch-a",
+		Base:  "master",
+	})
+}
+
+func TestUpdaterWithNamePattern(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, "environments/test/services/repository/test.yaml", "master", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	configs := createConfigs()
+	configs.Repositories[0].Name = ""
+	configs.Repositories[0].NameMatch = "mynamespace/(.*)"
+	configs.Repositories[0].FilePath = "environments/test/services/$1/test.yaml"
+	configs.Repositories[0].BranchGenerateName = "update-$1-"
+	applier := makeApplier(t, m, configs)
+	hook := createHook()
+
+	err := applier.UpdateFromHook(context.Background(), hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "test:\n  image: quay.io/testorg/repo:production\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, "environments/test/services/repository/test.yaml", "update-repository-a")); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+	m.AssertPullRequestCreated(testGitHubRepo, &scm.PullRequestInput{
+		Title: "Automated image update",
+		Body:  fmt.Sprintf("Automated update from %q", testQuayRepo),
+		Head:  "update-repository-a",
+		Base:  "master",
+	})
+}
+
+func TestUpdaterWithSetConfig(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	m := mock.New(t)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	applier := makeApplier(t, m, &config.RepoConfiguration{})
+	hook := createHook()
+
+	if err := applier.UpdateFromHook(context.Background(), hook); err != nil {
+		t.Fatal(err)
+	}
+	m.AssertNoInteractions()
+
+	applier.SetConfig(createConfigs())
+	if err := applier.UpdateFromHook(context.Background(), hook); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "test:\n  image: quay.io/testorg/repo:production\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+}
+
+func TestUpdaterWithPinDigest(t *testing.T) {
+	testDigest := "sha256:6ec128e26cd5da4f1f2f6d2bd0e0a4c6b8f1e9f3e5a9e7d4b1c2a3f4e5d6c7b8"
+	pinTests := []struct {
+		name      string
+		pinDigest string
+		digest    string
+		want      string
+		wantErr   string
+	}{
+		{"no pinning", "", testDigest, "quay.io/testorg/repo:production", ""},
+		{"with tag", config.PinDigestWithTag, testDigest, "quay.io/testorg/repo:production@" + testDigest, ""},
+		{"digest only", config.PinDigestOnly, testDigest, "quay.io/testorg/repo@" + testDigest, ""},
+		{"missing digest", config.PinDigestWithTag, "", "", "has no digest"},
+		{"invalid digest", config.PinDigestOnly, "sha256:6ec1", "", "invalid digest"},
+		{"unknown pinning", "always", testDigest, "", `invalid pinDigest "always"`},
+	}
+
+	for _, tt := range pinTests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+			m := mock.New(t)
+			m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+			m.AddBranchHead(testGitHubRepo, "master", testSHA)
+			configs := createConfigs()
+			configs.Repositories[0].PinDigest = tt.pinDigest
+			applier := makeApplier(t, m, configs)
+			hook := digestHook{RepositoryPushHook: createHook(), digest: tt.digest}
+
+			err := applier.UpdateFromHook(context.Background(), hook)
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+
+			if tt.wantErr != "" {
+				m.AssertNoInteractions()
+				return
+			}
+			want := "test:\n  image: " + tt.want + "\n"
+			if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")); s != want {
+				t.Fatalf("update failed, got %#v, want %#v", s, want)
+			}
+		})
+	}
+}
+
+func TestImageURLWithUnparseableDigest(t *testing.T) {
+	cfg := createConfigs().Repositories[0]
+	cfg.PinDigest = config.PinDigestWithTag
+	hook := digestHook{RepositoryPushHook: createHook(), err: errors.New("no digest in \"quay.io/testorg/repo:production\"")}
+
+	_, err := ImageURL(cfg, hook)
+
+	want := `repository mynamespace/repository pins digests, but the hook for quay.io/testorg/repo:production has an invalid digest: no digest in "quay.io/testorg/repo:production"`
+	if !test.MatchError(t, want, err) {
+		t.Fatalf("got error %v, want %s", err, want)
+	}
+}
+
 func TestUpdaterWithCreatePullRequestFailure(t *testing.T) {
 	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
 	m := mock.New(t)
@@ -213,7 +782,7 @@ func TestUpdaterWithCreatePullRequestFailure(t *testing.T) {
 
 	err := applier.UpdateFromHook(context.Background(), hook)
 
-	if err.Error() != "failed to create pull request in repo testorg/testrepo: failed to create a pull request: failure" {
+	if err.Error() != "failed to update 1 of 1 targets: testorg/testrepo:environments/test/services/service-a/test.yaml: failed to create pull request in repo testorg/testrepo: failed to create a pull request: failure" {
 		t.Fatalf("got %s, want %s", err, "failed to create a pull request: can't create pull-request")
 	}
 	updated := m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")
@@ -259,6 +828,40 @@ func TestUpdaterWithNonMasterSourceBranch(t *testing.T) {
 	})
 }
 
+func TestFindConfigs(t *testing.T) {
+	findTests := []struct {
+		name     string
+		tagMatch string
+		repo     string
+		want     bool
+		wantErr  string
+	}{
+		{"matching repo", "", testQuayRepo, true, ""},
+		{"unknown repo", "", "unknown/repo", false, ""},
+		{"matching tag", "^production", testQuayRepo, true, ""},
+		{"non-matching tag", "^v", testQuayRepo, false, ""},
+		{"invalid tagMatch", "[", testQuayRepo, false, "failed to compile TagMatch"},
+	}
+
+	for _, tt := range findTests {
+		t.Run(tt.name, func(t *testing.T) {
+			configs := createConfigs()
+			configs.Repositories[0].TagMatch = tt.tagMatch
+			applier := makeApplier(t, mock.New(t), configs)
+			hook := createHook()
+			hook.Repository = tt.repo
+
+			cfgs, err := applier.FindConfigs(hook)
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if found := len(cfgs) == 1; found != tt.want {
+				t.Fatalf("got configs %#v, want found %v", cfgs, tt.want)
+			}
+		})
+	}
+}
+
 func makeApplier(t *testing.T, m *mock.MockClient, cfgs *config.RepoConfiguration) *Applier {
 	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
 	applier := New(logger, m, cfgs, updater.NameGenerator(stubNameGenerator{name: "a"}))
@@ -288,6 +891,17 @@ func createConfigs() *config.RepoConfiguration {
 	}
 }
 
+// digestHook is a quay hook with a digest, quay hooks don't provide digests.
+type digestHook struct {
+	*quay.RepositoryPushHook
+	digest string
+	err    error
+}
+
+func (h digestHook) ImageDigest() (string, error) {
+	return h.digest, h.err
+}
+
 type stubNameGenerator struct {
 	name string
 }
@@ -295,3 +909,50 @@ type stubNameGenerator struct {
 func (s stubNameGenerator) PrefixedName(p string) string {
 	return p + s.name
 }
+
+// conflictingClient fails to update files with an error, by default a
+// Conflict classified as the retry.Transport would for a 409 response, until
+// the configured number of conflicts has been returned.
+//
+// If path is set, only updates to the path fail.
+//
+// It also counts the branches and pull requests that are created, and if
+// delay is set, waits before returning files, so that concurrent updates
+// overlap.
+type conflictingClient struct {
+	*mock.MockClient
+	conflicts    int
+	calls        int
+	branches     int
+	pullRequests int
+	path         string
+	err          error
+	delay        time.Duration
+}
+
+func (c *conflictingClient) GetFile(ctx context.Context, repo, ref, path string) (*scm.Content, error) {
+	time.Sleep(c.delay)
+	return c.MockClient.GetFile(ctx, repo, ref, path)
+}
+
+func (c *conflictingClient) CreateBranch(ctx context.Context, repo, branch, sha string) error {
+	c.branches++
+	return c.MockClient.CreateBranch(ctx, repo, branch, sha)
+}
+
+func (c *conflictingClient) CreatePullRequest(ctx context.Context, repo string, inp *scm.PullRequestInput) (*scm.PullRequest, error) {
+	c.pullRequests++
+	return c.MockClient.CreatePullRequest(ctx, repo, inp)
+}
+
+func (c *conflictingClient) UpdateFile(ctx context.Context, repo, branch, path, message, previousSHA string, content []byte) error {
+	c.calls++
+	if c.conflicts > 0 && (c.path == "" || c.path == path) {
+		c.conflicts--
+		if c.err != nil {
+			return c.err
+		}
+		return &retry.Error{Err: errors.New(http.StatusText(http.StatusConflict)), Conflict: true}
+	}
+	return c.MockClient.UpdateFile(ctx, repo, branch, path, message, previousSHA, content)
+}
@@ -0,0 +1,37 @@
+package applier
+
+import (
+	"context"
+
+	"github.com/gitops-tools/pkg/client"
+)
+
+type createdBranchKey struct{}
+
+// branchRecorder is a GitClient that records the branches that are created
+// with a context from withCreatedBranch.
+//
+// The updater creates the branch and commits in one call, so if the commit
+// fails after the branch was created, this is the only way to find the
+// branch, and retry the commit to it, rather than creating another branch.
+type branchRecorder struct {
+	client.GitClient
+}
+
+// CreateBranch implements the client.GitClient interface.
+func (c branchRecorder) CreateBranch(ctx context.Context, repo, branch, sha string) error {
+	if err := c.GitClient.CreateBranch(ctx, repo, branch, sha); err != nil {
+		return err
+	}
+	if p, ok := ctx.Value(createdBranchKey{}).(*string); ok {
+		*p = branch
+	}
+	return nil
+}
+
+// withCreatedBranch returns a context that records the name of the branch
+// created with it.
+func withCreatedBranch(ctx context.Context) (context.Context, *string) {
+	var created string
+	return context.WithValue(ctx, createdBranchKey{}, &created), &created
+}
@@ -0,0 +1,83 @@
+package applier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gitops-tools/image-updater/pkg/config"
+	"github.com/gitops-tools/image-updater/pkg/retry"
+)
+
+// Result is the outcome of updating the target of a configuration.
+type Result struct {
+	Config *config.Repository
+	Err    error
+}
+
+// UpdateError is returned when updating any of the targets for a hook fails,
+// it reports which targets were updated and which failed.
+type UpdateError struct {
+	Results []Result
+}
+
+func (e *UpdateError) Error() string {
+	var failed, updated []string
+	for _, r := range e.Results {
+		if r.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", target(r.Config), r.Err))
+			continue
+		}
+		updated = append(updated, target(r.Config))
+	}
+	msg := fmt.Sprintf("failed to update %d of %d targets: %s", len(failed), len(e.Results), strings.Join(failed, "; "))
+	if len(updated) > 0 {
+		msg += fmt.Sprintf(" (updated: %s)", strings.Join(updated, ", "))
+	}
+	return msg
+}
+
+// Unwrap returns the errors for the targets that failed.
+func (e *UpdateError) Unwrap() []error {
+	var errs []error
+	for _, r := range e.Results {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errs
+}
+
+// Aggregate returns an UpdateError if updating any of the targets failed.
+//
+// If any of the failures are transient, the UpdateError is wrapped in a
+// transient retry.Error, so that the hook is redelivered.
+func Aggregate(results []Result) error {
+	transient := false
+	var retryAfter time.Duration
+	failed := false
+	for _, r := range results {
+		if r.Err == nil {
+			continue
+		}
+		failed = true
+		if retry.IsTransient(r.Err) {
+			transient = true
+			if d := retry.RetryAfter(r.Err); d > retryAfter {
+				retryAfter = d
+			}
+		}
+	}
+	if !failed {
+		return nil
+	}
+	err := &UpdateError{Results: results}
+	if transient {
+		return &retry.Error{Err: err, Transient: true, RetryAfter: retryAfter}
+	}
+	return err
+}
+
+func target(cfg *config.Repository) string {
+	return cfg.SourceRepo + ":" + strings.Join(cfg.FilePaths(), ",")
+}
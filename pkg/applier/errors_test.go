@@ -0,0 +1,57 @@
+package applier
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gitops-tools/image-updater/pkg/config"
+	"github.com/gitops-tools/image-updater/pkg/retry"
+	"github.com/gitops-tools/image-updater/test"
+)
+
+func TestAggregate(t *testing.T) {
+	staging := &config.Repository{SourceRepo: "testorg/staging", FilePath: "deploy.yaml"}
+	production := &config.Repository{SourceRepo: "testorg/production", FilePath: "deploy.yaml"}
+	testErr := errors.New("failed")
+	transientErr := &retry.Error{Err: errors.New("unavailable"), Transient: true, RetryAfter: time.Minute}
+
+	aggregateTests := []struct {
+		name           string
+		results        []Result
+		wantErr        string
+		wantTransient  bool
+		wantRetryAfter time.Duration
+	}{
+		{"no targets", nil, "", false, 0},
+		{"all updated", []Result{{Config: staging}, {Config: production}}, "", false, 0},
+		{
+			"permanent failure",
+			[]Result{{Config: staging}, {Config: production, Err: testErr}},
+			`failed to update 1 of 2 targets: testorg/production:deploy.yaml: failed \(updated: testorg/staging:deploy.yaml\)`,
+			false, 0,
+		},
+		{
+			"transient failure",
+			[]Result{{Config: staging, Err: testErr}, {Config: production, Err: transientErr}},
+			`failed to update 2 of 2 targets: testorg/staging:deploy.yaml: failed; testorg/production:deploy.yaml: unavailable$`,
+			true, time.Minute,
+		},
+	}
+
+	for _, tt := range aggregateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Aggregate(tt.results)
+
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if transient := retry.IsTransient(err); transient != tt.wantTransient {
+				t.Fatalf("got transient %v, want %v", transient, tt.wantTransient)
+			}
+			if d := retry.RetryAfter(err); d != tt.wantRetryAfter {
+				t.Fatalf("got retry after %v, want %v", d, tt.wantRetryAfter)
+			}
+		})
+	}
+}
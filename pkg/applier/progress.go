@@ -0,0 +1,76 @@
+package applier
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/gitops-tools/image-updater/pkg/config"
+	"github.com/gitops-tools/image-updater/pkg/keylock"
+)
+
+// maxProgress is the number of updates that progress is recorded for, when
+// there are more, the oldest records are dropped.
+const maxProgress = 1000
+
+// progress records the progress of updates, so that when a hook is
+// redelivered, updates that completed are not applied again, and updates
+// that failed part-way continue in the same branch, rather than leaving a
+// branch with some of the files updated, and creating another.
+//
+// The records are kept in memory, so they are lost when the service restarts.
+//
+// Concurrent updates with the same key are serialized with lock, so that
+// only one of them applies the update, and the others find its record.
+type progress struct {
+	locks   *keylock.Locker
+	mu      sync.Mutex
+	keys    []string
+	records map[string]record
+}
+
+// record is the progress of an update.
+type record struct {
+	// branch is the branch that the update was committed to.
+	branch string
+
+	// done is true if the update was completed.
+	done bool
+}
+
+func newProgress() *progress {
+	return &progress{locks: keylock.New(), records: map[string]record{}}
+}
+
+// lock blocks until the lock for the update is acquired, and returns a
+// function that releases it.
+func (p *progress) lock(key string) func() {
+	return p.locks.Lock(key)
+}
+
+// get returns the record for the update, or an empty record if there isn't
+// one.
+func (p *progress) get(key string) record {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.records[key]
+}
+
+// set records the progress of the update.
+func (p *progress) set(key string, r record) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.records[key]; !ok {
+		p.keys = append(p.keys, key)
+	}
+	p.records[key] = r
+	for len(p.keys) > maxProgress {
+		delete(p.records, p.keys[0])
+		p.keys = p.keys[1:]
+	}
+}
+
+// progressKey identifies the update of the files in a repository with an
+// image.
+func progressKey(cfg *config.Repository, newURL string) string {
+	return strings.Join([]string{target(cfg), cfg.SourceBranch, newURL}, "|")
+}
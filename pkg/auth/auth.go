@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultSecretHeader is the header that the shared secret is read from
+	// unless another header is configured.
+	DefaultSecretHeader = "X-Webhook-Secret"
+
+	// DefaultSignatureHeader is the header that the HMAC signature is read
+	// from unless another header is configured.
+	DefaultSignatureHeader = "X-Hub-Signature-256"
+
+	// SecretQueryParameter is the query parameter that can be used to provide
+	// the shared secret instead of a header.
+	SecretQueryParameter = "secret"
+
+	signaturePrefix = "sha256="
+)
+
+// ErrUnauthorized is wrapped by errors returned when a request fails
+// authentication.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Authenticator is implemented by values that can authenticate hook requests.
+//
+// The body is provided separately because the request body has already been
+// consumed.
+type Authenticator interface {
+	Authenticate(r *http.Request, body []byte) error
+}
+
+// Config configures the authentication of incoming hook requests.
+//
+// Each of the configured methods must succeed for a request to be
+// authenticated, if no methods are configured, all requests are accepted.
+type Config struct {
+	// Secret is a shared secret that must be provided in the SecretHeader
+	// or the "secret" query parameter.
+	Secret       string `json:"secret,omitempty"`
+	SecretHeader string `json:"secretHeader,omitempty"`
+
+	// HMACSecret is used to verify an HMAC-SHA256 signature of the request
+	// body, provided in the SignatureHeader as a hex string, optionally
+	// prefixed with "sha256=".
+	HMACSecret      string `json:"hmacSecret,omitempty"`
+	SignatureHeader string `json:"signatureHeader,omitempty"`
+
+	// Username and Password are required as HTTP basic authentication.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// ForParser returns a copy of the configuration with the headers defaulted
+// for the named hook parser.
+//
+// Harbor sends its configured "Auth Header" in the Authorization header.
+func ForParser(parser string, c Config) *Config {
+	if c.SecretHeader == "" {
+		c.SecretHeader = DefaultSecretHeader
+		if parser == "harbor" {
+			c.SecretHeader = "Authorization"
+		}
+	}
+	if c.SignatureHeader == "" {
+		c.SignatureHeader = DefaultSignatureHeader
+	}
+	return &c
+}
+
+// Enabled returns true if any authentication method is configured.
+func (c Config) Enabled() bool {
+	return c.Secret != "" || c.HMACSecret != "" || c.Username != ""
+}
+
+// Authenticate is an implementation of the Authenticator interface.
+func (c Config) Authenticate(r *http.Request, body []byte) error {
+	if c.Username != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			return fmt.Errorf("%w: no basic auth credentials", ErrUnauthorized)
+		}
+		if !equal(username, c.Username) || !equal(password, c.Password) {
+			return fmt.Errorf("%w: invalid basic auth credentials", ErrUnauthorized)
+		}
+	}
+	if c.Secret != "" {
+		secret := r.Header.Get(c.secretHeader())
+		if secret == "" {
+			secret = r.URL.Query().Get(SecretQueryParameter)
+		}
+		if secret == "" {
+			return fmt.Errorf("%w: no shared secret", ErrUnauthorized)
+		}
+		if !equal(secret, c.Secret) {
+			return fmt.Errorf("%w: invalid shared secret", ErrUnauthorized)
+		}
+	}
+	if c.HMACSecret != "" {
+		signature := r.Header.Get(c.signatureHeader())
+		if signature == "" {
+			return fmt.Errorf("%w: no signature in %s header", ErrUnauthorized, c.signatureHeader())
+		}
+		if !validSignature(c.HMACSecret, strings.TrimPrefix(signature, signaturePrefix), body) {
+			return fmt.Errorf("%w: invalid signature", ErrUnauthorized)
+		}
+	}
+	return nil
+}
+
+// Sign returns the HMAC-SHA256 signature of the body in the same format as
+// GitHub's X-Hub-Signature-256 header.
+func Sign(secret string, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(mac(secret, body))
+}
+
+func (c Config) secretHeader() string {
+	if c.SecretHeader == "" {
+		return DefaultSecretHeader
+	}
+	return c.SecretHeader
+}
+
+func (c Config) signatureHeader() string {
+	if c.SignatureHeader == "" {
+		return DefaultSignatureHeader
+	}
+	return c.SignatureHeader
+}
+
+func validSignature(secret, signature string, body []byte) bool {
+	decoded, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(decoded, mac(secret, body))
+}
+
+func mac(secret string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	_, _ = h.Write(body)
+	return h.Sum(nil)
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
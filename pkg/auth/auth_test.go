@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gitops-tools/image-updater/test"
+)
+
+var _ Authenticator = Config{}
+
+const testBody = `{"repository":"mynamespace/repository"}`
+
+func TestAuthenticate(t *testing.T) {
+	authTests := []struct {
+		name    string
+		cfg     Config
+		req     func(*http.Request)
+		wantErr string
+	}{
+		{"no authentication", Config{}, func(*http.Request) {}, ""},
+		{"secret in default header", Config{Secret: "test"}, header(DefaultSecretHeader, "test"), ""},
+		{"secret in custom header", Config{Secret: "test", SecretHeader: "Authorization"}, header("Authorization", "test"), ""},
+		{"secret in query", Config{Secret: "test"}, query("secret=test"), ""},
+		{"missing secret", Config{Secret: "test"}, func(*http.Request) {}, "unauthorized: no shared secret"},
+		{"invalid secret", Config{Secret: "test"}, query("secret=wrong"), "unauthorized: invalid shared secret"},
+		{"secret in wrong header", Config{Secret: "test", SecretHeader: "Authorization"}, header(DefaultSecretHeader, "test"), "unauthorized: no shared secret"},
+		{"valid signature", Config{HMACSecret: "test"}, header(DefaultSignatureHeader, Sign("test", []byte(testBody))), ""},
+		{"valid unprefixed signature", Config{HMACSecret: "test", SignatureHeader: "X-Signature"}, header("X-Signature", strings.TrimPrefix(Sign("test", []byte(testBody)), "sha256=")), ""},
+		{"missing signature", Config{HMACSecret: "test"}, func(*http.Request) {}, "unauthorized: no signature in X-Hub-Signature-256 header"},
+		{"invalid signature", Config{HMACSecret: "test"}, header(DefaultSignatureHeader, Sign("wrong", []byte(testBody))), "unauthorized: invalid signature"},
+		{"non-hex signature", Config{HMACSecret: "test"}, header(DefaultSignatureHeader, "sha256=not-hex"), "unauthorized: invalid signature"},
+		{"valid basic auth", Config{Username: "user", Password: "pass"}, basicAuth("user", "pass"), ""},
+		{"missing basic auth", Config{Username: "user", Password: "pass"}, func(*http.Request) {}, "unauthorized: no basic auth credentials"},
+		{"invalid basic auth", Config{Username: "user", Password: "pass"}, basicAuth("user", "wrong"), "unauthorized: invalid basic auth credentials"},
+		{"all methods", Config{Username: "user", Password: "pass", Secret: "test", HMACSecret: "test"}, all(basicAuth("user", "pass"), query("secret=test"), header(DefaultSignatureHeader, Sign("test", []byte(testBody)))), ""},
+		{"all methods with a failure", Config{Username: "user", Password: "pass", Secret: "test", HMACSecret: "test"}, all(basicAuth("user", "pass"), query("secret=test")), "unauthorized: no signature"},
+	}
+
+	for _, tt := range authTests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(testBody))
+			tt.req(req)
+
+			err := tt.cfg.Authenticate(req, []byte(testBody))
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestForParser(t *testing.T) {
+	parserTests := []struct {
+		parser string
+		cfg    Config
+		want   *Config
+	}{
+		{"quay", Config{Secret: "test"}, &Config{Secret: "test", SecretHeader: DefaultSecretHeader, SignatureHeader: DefaultSignatureHeader}},
+		{"harbor", Config{Secret: "test"}, &Config{Secret: "test", SecretHeader: "Authorization", SignatureHeader: DefaultSignatureHeader}},
+		{"harbor", Config{Secret: "test", SecretHeader: "X-Secret"}, &Config{Secret: "test", SecretHeader: "X-Secret", SignatureHeader: DefaultSignatureHeader}},
+	}
+
+	for _, tt := range parserTests {
+		if diff := cmp.Diff(tt.want, ForParser(tt.parser, tt.cfg)); diff != "" {
+			t.Errorf("ForParser(%s) failed:\n%s", tt.parser, diff)
+		}
+	}
+}
+
+func header(k, v string) func(*http.Request) {
+	return func(r *http.Request) {
+		r.Header.Set(k, v)
+	}
+}
+
+func query(q string) func(*http.Request) {
+	return func(r *http.Request) {
+		r.URL.RawQuery = q
+	}
+}
+
+func basicAuth(username, password string) func(*http.Request) {
+	return func(r *http.Request) {
+		r.SetBasicAuth(username, password)
+	}
+}
+
+func all(fs ...func(*http.Request)) func(*http.Request) {
+	return func(r *http.Request) {
+		for _, f := range fs {
+			f(r)
+		}
+	}
+}
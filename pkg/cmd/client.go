@@ -8,24 +8,37 @@ import (
 	"github.com/jenkins-x/go-scm/scm/factory"
 	"github.com/spf13/viper"
 	"golang.org/x/oauth2"
+
+	"github.com/gitops-tools/image-updater/pkg/retry"
 )
 
 func createClientFromViper() (*scm.Client, error) {
 	authToken := viper.GetString(authTokenFlag)
 	driver := viper.GetString(driverFlag)
 	apiEndpoint := viper.GetString(apiEndpointFlag)
+	var opts []factory.ClientOptionFunc
 	if viper.GetBool(insecureFlag) {
-		return factory.NewClient(
-			driver,
-			apiEndpoint,
-			"",
-			factory.Client(makeInsecureClient(authToken)))
+		opts = append(opts, factory.Client(makeInsecureClient(authToken)))
+		authToken = ""
+	}
+	c, err := factory.NewClient(driver, apiEndpoint, authToken, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.Client = recordErrorResponses(c.Client)
+	return c, nil
+}
 
+// recordErrorResponses returns a copy of the client with a transport that
+// records error responses so that errors can be retried, the drivers don't
+// return the response status and headers with errors.
+func recordErrorResponses(c *http.Client) *http.Client {
+	if c == nil {
+		c = http.DefaultClient
 	}
-	return factory.NewClient(
-		driver,
-		apiEndpoint,
-		authToken)
+	wrapped := *c
+	wrapped.Transport = retry.NewTransport(c.Transport)
+	return &wrapped
 }
 
 func makeInsecureClient(token string) *http.Client {
@@ -1,9 +1,10 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"os"
+	"strings"
 
 	"github.com/go-logr/zapr"
 	"github.com/spf13/cobra"
@@ -11,14 +12,34 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/gitops-tools/image-updater/pkg/applier"
+	"github.com/gitops-tools/image-updater/pkg/auth"
 	"github.com/gitops-tools/image-updater/pkg/config"
 	"github.com/gitops-tools/image-updater/pkg/handler"
 	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/hooks/auto"
+	"github.com/gitops-tools/image-updater/pkg/hooks/distribution"
 	"github.com/gitops-tools/image-updater/pkg/hooks/docker"
+	"github.com/gitops-tools/image-updater/pkg/hooks/ghcr"
+	"github.com/gitops-tools/image-updater/pkg/hooks/harbor"
 	"github.com/gitops-tools/image-updater/pkg/hooks/quay"
+	"github.com/gitops-tools/image-updater/pkg/queue"
 	"github.com/gitops-tools/pkg/client"
 )
 
+const (
+	webhookSecretFlag          = "webhook-secret"
+	webhookSecretHeaderFlag    = "webhook-secret-header"
+	webhookHMACSecretFlag      = "webhook-hmac-secret"
+	webhookSignatureHeaderFlag = "webhook-signature-header"
+	webhookUsernameFlag        = "webhook-username"
+	webhookPasswordFlag        = "webhook-password"
+	maxBodySizeFlag            = "max-body-size"
+	routeFlag                  = "route"
+	workersFlag                = "workers"
+	queueSizeFlag              = "queue-size"
+	retryAfterFlag             = "retry-after"
+)
+
 func makeHTTPCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "http",
@@ -29,29 +50,47 @@ func makeHTTPCmd() *cobra.Command {
 				_ = zapl.Sync() // flushes buffer, if any
 			}()
 			logger := zapr.NewLogger(zapl)
+			if err := validateHTTPFlags(); err != nil {
+				return err
+			}
 			scmClient, err := createClientFromViper()
 			if err != nil {
 				return fmt.Errorf("failed to create a git driver: %s", err)
 			}
-			f, err := os.Open(viper.GetString("config"))
+			repos, err := config.Load(viper.GetString("config"), configOptions()...)
 			if err != nil {
 				return err
 			}
-			defer f.Close()
-			repos, err := config.Parse(f)
-			if err != nil {
-				return err
+			applier := configureApplier(applier.New(logger, client.New(scmClient), repos))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			watchConfig(ctx, logger, applier)
+			var q *queue.Queue
+			if workers := viper.GetInt(workersFlag); workers > 0 {
+				q = queue.New(logger, applier, viper.GetInt(queueSizeFlag))
+				q.Start(ctx, workers)
+				defer q.Stop()
 			}
-			applier := applier.New(logger, client.New(scmClient), repos)
-			p, err := parser()
+			routes, err := makeHooks(viper.GetStringSlice(routeFlag), repos, viper.GetString("parser"))
 			if err != nil {
 				return err
 			}
-			handler := handler.New(logger, applier, p)
-			http.Handle("/", handler)
+			mux := http.NewServeMux()
+			for _, h := range routes {
+				p, err := parser(h.Parser)
+				if err != nil {
+					return err
+				}
+				opts := handlerOptions(h)
+				if q != nil {
+					opts = append(opts, handler.Queue(q, viper.GetDuration(retryAfterFlag)))
+				}
+				mux.Handle(h.Path, handler.New(logger, applier, p, opts...))
+				logger.Info("handling hooks", "path", h.Path, "parser", h.Parser)
+			}
 			listen := fmt.Sprintf(":%d", viper.GetInt("port"))
-			logger.Info("quay-hooks http starting", "port", viper.GetInt("port"), "parser", viper.GetString("parser"))
-			return http.ListenAndServe(listen, nil)
+			logger.Info("image-updater http starting", "port", viper.GetInt("port"))
+			return http.ListenAndServe(listen, mux)
 		},
 	}
 
@@ -65,10 +104,17 @@ func makeHTTPCmd() *cobra.Command {
 	cmd.Flags().String(
 		"parser",
 		"quay",
-		"what driver to use to parse incoming webhooks e.g. quay, docker",
+		"what driver to use to parse incoming webhooks e.g. quay, docker, ghcr, harbor, distribution, auto",
 	)
 	logIfError(viper.BindPFlag("parser", cmd.Flags().Lookup("parser")))
 
+	cmd.Flags().StringArray(
+		routeFlag,
+		nil,
+		"path and parser to handle hooks with e.g. /hooks/quay=quay, can be repeated, overrides --parser",
+	)
+	logIfError(viper.BindPFlag(routeFlag, cmd.Flags().Lookup(routeFlag)))
+
 	cmd.Flags().String(
 		"config",
 		"/etc/image-updater/config.yaml",
@@ -76,16 +122,181 @@ func makeHTTPCmd() *cobra.Command {
 	)
 	logIfError(viper.BindPFlag("config", cmd.Flags().Lookup("config")))
 
+	cmd.Flags().Bool(
+		watchConfigFlag,
+		true,
+		"reload the repository configuration when the file or the files that it includes change",
+	)
+	logIfError(viper.BindPFlag(watchConfigFlag, cmd.Flags().Lookup(watchConfigFlag)))
+
+	cmd.Flags().Int64(
+		maxBodySizeFlag,
+		handler.DefaultMaxBodySize,
+		"maximum size in bytes of hook request bodies, larger requests are rejected",
+	)
+	logIfError(viper.BindPFlag(maxBodySizeFlag, cmd.Flags().Lookup(maxBodySizeFlag)))
+
+	cmd.Flags().Int(
+		workersFlag,
+		0,
+		"number of workers that process queued hook events, if 0, hooks are processed before responding",
+	)
+	logIfError(viper.BindPFlag(workersFlag, cmd.Flags().Lookup(workersFlag)))
+
+	cmd.Flags().Int(
+		queueSizeFlag,
+		100,
+		"maximum number of hooks waiting to be processed, when the queue is full, hooks are rejected with 503",
+	)
+	logIfError(viper.BindPFlag(queueSizeFlag, cmd.Flags().Lookup(queueSizeFlag)))
+
+	cmd.Flags().Duration(
+		retryAfterFlag,
+		handler.DefaultRetryAfter,
+		"delay that registries are asked to wait before retrying when the queue is full, or updates fail with transient errors",
+	)
+	logIfError(viper.BindPFlag(retryAfterFlag, cmd.Flags().Lookup(retryAfterFlag)))
+
+	addWebhookAuthFlags(cmd)
+
 	return cmd
 }
 
-func parser() (hooks.PushEventParser, error) {
-	switch viper.GetString("parser") {
+func addWebhookAuthFlags(cmd *cobra.Command) {
+	cmd.Flags().String(
+		webhookSecretFlag,
+		"",
+		"shared secret that hook requests must provide in the secret header or the \"secret\" query parameter",
+	)
+	logIfError(viper.BindPFlag(webhookSecretFlag, cmd.Flags().Lookup(webhookSecretFlag)))
+
+	cmd.Flags().String(
+		webhookSecretHeaderFlag,
+		"",
+		"header to read the shared secret from, defaults to Authorization for harbor and X-Webhook-Secret otherwise",
+	)
+	logIfError(viper.BindPFlag(webhookSecretHeaderFlag, cmd.Flags().Lookup(webhookSecretHeaderFlag)))
+
+	cmd.Flags().String(
+		webhookHMACSecretFlag,
+		"",
+		"secret used to verify the HMAC-SHA256 signature of hook request bodies",
+	)
+	logIfError(viper.BindPFlag(webhookHMACSecretFlag, cmd.Flags().Lookup(webhookHMACSecretFlag)))
+
+	cmd.Flags().String(
+		webhookSignatureHeaderFlag,
+		"",
+		"header to read the HMAC-SHA256 signature from, defaults to X-Hub-Signature-256",
+	)
+	logIfError(viper.BindPFlag(webhookSignatureHeaderFlag, cmd.Flags().Lookup(webhookSignatureHeaderFlag)))
+
+	cmd.Flags().String(
+		webhookUsernameFlag,
+		"",
+		"username that hook requests must provide with basic auth",
+	)
+	logIfError(viper.BindPFlag(webhookUsernameFlag, cmd.Flags().Lookup(webhookUsernameFlag)))
+
+	cmd.Flags().String(
+		webhookPasswordFlag,
+		"",
+		"password that hook requests must provide with basic auth",
+	)
+	logIfError(viper.BindPFlag(webhookPasswordFlag, cmd.Flags().Lookup(webhookPasswordFlag)))
+}
+
+// validateHTTPFlags returns an error if the flags that configure how hooks are
+// processed are out of range.
+func validateHTTPFlags() error {
+	if n := viper.GetInt64(maxBodySizeFlag); n <= 0 {
+		return fmt.Errorf("invalid --%s %d, must be greater than 0", maxBodySizeFlag, n)
+	}
+	if n := viper.GetInt(workersFlag); n < 0 {
+		return fmt.Errorf("invalid --%s %d, must be 0 or more", workersFlag, n)
+	}
+	if n := viper.GetInt(queueSizeFlag); n < 0 {
+		return fmt.Errorf("invalid --%s %d, must be 0 or more", queueSizeFlag, n)
+	}
+	if d := viper.GetDuration(retryAfterFlag); d < 0 {
+		return fmt.Errorf("invalid --%s %s, must be 0 or more", retryAfterFlag, d)
+	}
+	return nil
+}
+
+func authFromViper(parser string) *auth.Config {
+	return auth.ForParser(parser, auth.Config{
+		Secret:          viper.GetString(webhookSecretFlag),
+		SecretHeader:    viper.GetString(webhookSecretHeaderFlag),
+		HMACSecret:      viper.GetString(webhookHMACSecretFlag),
+		SignatureHeader: viper.GetString(webhookSignatureHeaderFlag),
+		Username:        viper.GetString(webhookUsernameFlag),
+		Password:        viper.GetString(webhookPasswordFlag),
+	})
+}
+
+// makeHooks returns the hooks to serve from the routes (in the form
+// path=parser) and the hooks in the configuration.
+//
+// If neither configure any hooks, the default parser is served at "/".
+func makeHooks(routes []string, cfg *config.RepoConfiguration, defaultParser string) ([]*config.Hook, error) {
+	configured := append([]*config.Hook{}, cfg.Hooks...)
+	for _, r := range routes {
+		path, parser, ok := strings.Cut(r, "=")
+		if !ok || path == "" || parser == "" {
+			return nil, fmt.Errorf("invalid route %q, must be path=parser", r)
+		}
+		configured = append(configured, &config.Hook{Path: path, Parser: parser})
+	}
+	if len(configured) == 0 {
+		return []*config.Hook{{Path: "/", Parser: defaultParser}}, nil
+	}
+
+	paths := map[string]bool{}
+	for _, h := range configured {
+		if !strings.HasPrefix(h.Path, "/") {
+			return nil, fmt.Errorf("invalid hook path %q, must start with /", h.Path)
+		}
+		if paths[h.Path] {
+			return nil, fmt.Errorf("duplicate hook path %q", h.Path)
+		}
+		paths[h.Path] = true
+	}
+	return configured, nil
+}
+
+// handlerOptions returns the options for the handler for a hook, if the hook
+// has no authentication configured, the command-line configuration is used.
+func handlerOptions(h *config.Hook) []handler.Option {
+	opts := []handler.Option{
+		handler.MaxBodySize(viper.GetInt64(maxBodySizeFlag)),
+		handler.RetryAfter(viper.GetDuration(retryAfterFlag)),
+	}
+	a := authFromViper(h.Parser)
+	if h.Auth != nil {
+		a = auth.ForParser(h.Parser, *h.Auth)
+	}
+	if a.Enabled() {
+		opts = append(opts, handler.Authentication(a))
+	}
+	return opts
+}
+
+func parser(name string) (hooks.PushEventParser, error) {
+	switch name {
 	case "quay":
 		return quay.Parse, nil
 	case "docker":
 		return docker.Parse, nil
+	case "ghcr":
+		return ghcr.Parse, nil
+	case "harbor":
+		return harbor.Parse, nil
+	case "distribution":
+		return distribution.Parse, nil
+	case "auto":
+		return auto.Parse, nil
 	default:
-		return nil, fmt.Errorf("unknown parser: %s", viper.GetString("parser"))
+		return nil, fmt.Errorf("unknown parser: %s", name)
 	}
 }
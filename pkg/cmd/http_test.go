@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gitops-tools/image-updater/pkg/auth"
+	"github.com/gitops-tools/image-updater/pkg/config"
+	"github.com/gitops-tools/image-updater/test"
+)
+
+func TestMakeHooks(t *testing.T) {
+	harborHook := &config.Hook{Path: "/hooks/harbor", Parser: "harbor", Auth: &auth.Config{Secret: "test"}}
+
+	hookTests := []struct {
+		name    string
+		routes  []string
+		hooks   []*config.Hook
+		want    []*config.Hook
+		wantErr string
+	}{
+		{"no routes or hooks", nil, nil, []*config.Hook{{Path: "/", Parser: "docker"}}, ""},
+		{
+			"routes", []string{"/hooks/quay=quay", "/hooks/docker=docker"}, nil,
+			[]*config.Hook{{Path: "/hooks/quay", Parser: "quay"}, {Path: "/hooks/docker", Parser: "docker"}}, "",
+		},
+		{
+			"configured hooks and routes", []string{"/hooks/quay=quay"}, []*config.Hook{harborHook},
+			[]*config.Hook{harborHook, {Path: "/hooks/quay", Parser: "quay"}}, "",
+		},
+		{"invalid route", []string{"/hooks/quay"}, nil, nil, `invalid route "/hooks/quay", must be path=parser`},
+		{"relative path", []string{"hooks/quay=quay"}, nil, nil, `invalid hook path "hooks/quay", must start with /`},
+		{"duplicate path", []string{"/hooks/harbor=quay"}, []*config.Hook{harborHook}, nil, `duplicate hook path "/hooks/harbor"`},
+	}
+
+	for _, tt := range hookTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := makeHooks(tt.routes, &config.RepoConfiguration{Hooks: tt.hooks}, "docker")
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("makeHooks() failed:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateHTTPFlags(t *testing.T) {
+	flagTests := []struct {
+		name    string
+		values  map[string]interface{}
+		wantErr string
+	}{
+		{"defaults", map[string]interface{}{maxBodySizeFlag: 1024}, ""},
+		{"workers", map[string]interface{}{maxBodySizeFlag: 1024, workersFlag: 4, queueSizeFlag: 10}, ""},
+		{"negative workers", map[string]interface{}{maxBodySizeFlag: 1024, workersFlag: -1}, "invalid --workers -1, must be 0 or more"},
+		{"negative queue size", map[string]interface{}{maxBodySizeFlag: 1024, queueSizeFlag: -1}, "invalid --queue-size -1, must be 0 or more"},
+		{"negative retry after", map[string]interface{}{maxBodySizeFlag: 1024, retryAfterFlag: "-1s"}, "invalid --retry-after -1s, must be 0 or more"},
+		{"zero max body size", map[string]interface{}{maxBodySizeFlag: 0}, "invalid --max-body-size 0, must be greater than 0"},
+		{"negative max body size", map[string]interface{}{maxBodySizeFlag: -1}, "invalid --max-body-size -1, must be greater than 0"},
+	}
+
+	for _, tt := range flagTests {
+		t.Run(tt.name, func(t *testing.T) {
+			setViper(t, tt.values)
+
+			err := validateHTTPFlags()
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
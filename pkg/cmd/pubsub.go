@@ -3,7 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
+	"strings"
 
 	"cloud.google.com/go/pubsub"
 	"github.com/gitops-tools/image-updater/pkg/applier"
@@ -11,6 +11,7 @@ import (
 	"github.com/gitops-tools/image-updater/pkg/hooks/gcr"
 	"github.com/gitops-tools/image-updater/pkg/pubsubhandler"
 	"github.com/gitops-tools/pkg/client"
+	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -18,16 +19,24 @@ import (
 )
 
 const (
-	projectIDFlag        = "project-id"
-	subscriptionNameFlag = "subscription-name"
+	projectIDFlag           = "project-id"
+	subscriptionNameFlag    = "subscription-name"
+	deadLetterTopicFlag     = "dead-letter-topic"
+	maxDeliveryAttemptsFlag = "max-delivery-attempts"
+	maxOutstandingFlag      = "max-outstanding-messages"
+	numGoroutinesFlag       = "num-goroutines"
+	maxExtensionFlag        = "max-extension"
+	createSubscriptionFlag  = "create-subscription"
+	topicFlag               = "topic"
 )
 
+// message wraps a pubsub.Message so that it can be acked and nacked by the
+// pubsubhandler.
 type message struct {
-	data []byte
+	*pubsub.Message
 }
 
-func (m *message) Ack()         {}
-func (m *message) Data() []byte { return m.data }
+func (m message) Data() []byte { return m.Message.Data }
 
 func makePubsubCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -43,29 +52,16 @@ func makePubsubCmd() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to create a git driver: %s", err)
 			}
-			f, err := os.Open(viper.GetString("config"))
+			repos, err := config.Load(viper.GetString("config"), configOptions()...)
 			if err != nil {
 				return err
 			}
-			defer f.Close()
-			repos, err := config.Parse(f)
-			if err != nil {
-				return err
-			}
-			applier := applier.New(logger, client.New(scmClient), repos)
-
-			sub, err := createSubscriptionFromViper()
-			if err != nil {
-				return err
-			}
-
-			handler := pubsubhandler.New(logger, applier, gcr.Parse)
+			applier := configureApplier(applier.New(logger, client.New(scmClient), repos))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			watchConfig(ctx, logger, applier)
 
-			return sub.Receive(context.Background(), func(ctx context.Context, msg *pubsub.Message) {
-				handler.Handle(ctx, &message{
-					data: msg.Data,
-				})
-			})
+			return receiveFromViper(ctx, logger, applier)
 		},
 	}
 
@@ -76,6 +72,13 @@ func makePubsubCmd() *cobra.Command {
 	)
 	logIfError(viper.BindPFlag("config", cmd.Flags().Lookup("config")))
 
+	cmd.Flags().Bool(
+		watchConfigFlag,
+		true,
+		"reload the repository configuration when the file or the files that it includes change",
+	)
+	logIfError(viper.BindPFlag(watchConfigFlag, cmd.Flags().Lookup(watchConfigFlag)))
+
 	cmd.Flags().String(
 		projectIDFlag,
 		"",
@@ -92,11 +95,90 @@ func makePubsubCmd() *cobra.Command {
 	logIfError(viper.BindPFlag(subscriptionNameFlag, cmd.Flags().Lookup(subscriptionNameFlag)))
 	logIfError(cmd.MarkFlagRequired(subscriptionNameFlag))
 
+	cmd.Flags().String(
+		deadLetterTopicFlag,
+		"",
+		"topic to forward messages to when processing keeps failing, the topic name or projects/<project>/topics/<topic>",
+	)
+	logIfError(viper.BindPFlag(deadLetterTopicFlag, cmd.Flags().Lookup(deadLetterTopicFlag)))
+
+	cmd.Flags().Int(
+		maxDeliveryAttemptsFlag,
+		5,
+		"number of delivery attempts before messages are forwarded to the dead-letter topic (5-100)",
+	)
+	logIfError(viper.BindPFlag(maxDeliveryAttemptsFlag, cmd.Flags().Lookup(maxDeliveryAttemptsFlag)))
+
+	cmd.Flags().Int(
+		maxOutstandingFlag,
+		pubsub.DefaultReceiveSettings.MaxOutstandingMessages,
+		"maximum number of messages that are being processed at the same time",
+	)
+	logIfError(viper.BindPFlag(maxOutstandingFlag, cmd.Flags().Lookup(maxOutstandingFlag)))
+
+	cmd.Flags().Int(
+		numGoroutinesFlag,
+		pubsub.DefaultReceiveSettings.NumGoroutines,
+		"number of goroutines that pull messages from the subscription",
+	)
+	logIfError(viper.BindPFlag(numGoroutinesFlag, cmd.Flags().Lookup(numGoroutinesFlag)))
+
+	cmd.Flags().Duration(
+		maxExtensionFlag,
+		pubsub.DefaultReceiveSettings.MaxExtension,
+		"maximum time that the deadline of a message is extended while it's processed",
+	)
+	logIfError(viper.BindPFlag(maxExtensionFlag, cmd.Flags().Lookup(maxExtensionFlag)))
+
+	cmd.Flags().Bool(
+		createSubscriptionFlag,
+		false,
+		"create the topic and subscription if they don't exist",
+	)
+	logIfError(viper.BindPFlag(createSubscriptionFlag, cmd.Flags().Lookup(createSubscriptionFlag)))
+
+	cmd.Flags().String(
+		topicFlag,
+		"gcr",
+		"topic to subscribe to when creating the subscription",
+	)
+	logIfError(viper.BindPFlag(topicFlag, cmd.Flags().Lookup(topicFlag)))
+
 	return cmd
 }
 
-func createSubscriptionFromViper() (*pubsub.Subscription, error) {
-	ctx := context.Background()
+// receiveFromViper receives messages from the configured subscription and
+// processes them with the applier until the context is cancelled.
+func receiveFromViper(ctx context.Context, logger logr.Logger, applier *applier.Applier) error {
+	sub, err := createSubscriptionFromViper(ctx)
+	if err != nil {
+		return err
+	}
+	if topic := viper.GetString(deadLetterTopicFlag); topic != "" {
+		if err := updateDeadLetterPolicy(ctx, sub, topic, viper.GetInt(maxDeliveryAttemptsFlag)); err != nil {
+			return err
+		}
+		logger.Info("configured dead-letter topic", "topic", topic)
+	}
+
+	sub.ReceiveSettings.MaxOutstandingMessages = viper.GetInt(maxOutstandingFlag)
+	sub.ReceiveSettings.NumGoroutines = viper.GetInt(numGoroutinesFlag)
+	sub.ReceiveSettings.MaxExtension = viper.GetDuration(maxExtensionFlag)
+
+	handler := pubsubhandler.New(logger, applier, gcr.Parse)
+
+	logger.Info("image-updater pubsub starting", "subscription", sub.String())
+	return sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		handler.Handle(ctx, message{Message: msg})
+	})
+}
+
+// createSubscriptionFromViper returns the configured subscription, creating
+// the topic and subscription if required.
+//
+// The client connects to the Pub/Sub emulator if the PUBSUB_EMULATOR_HOST
+// environment variable is set.
+func createSubscriptionFromViper(ctx context.Context) (*pubsub.Subscription, error) {
 	projectID := viper.GetString(projectIDFlag)
 	subscriptionName := viper.GetString(subscriptionNameFlag)
 
@@ -106,5 +188,57 @@ func createSubscriptionFromViper() (*pubsub.Subscription, error) {
 	}
 
 	sub := client.Subscription(subscriptionName)
+	if !viper.GetBool(createSubscriptionFlag) {
+		return sub, nil
+	}
+	exists, err := sub.Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for subscription %s: %w", subscriptionName, err)
+	}
+	if exists {
+		return sub, nil
+	}
+	topic, err := createTopic(ctx, client, viper.GetString(topicFlag))
+	if err != nil {
+		return nil, err
+	}
+	sub, err = client.CreateSubscription(ctx, subscriptionName, pubsub.SubscriptionConfig{Topic: topic})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscription %s: %w", subscriptionName, err)
+	}
 	return sub, nil
 }
+
+func createTopic(ctx context.Context, client *pubsub.Client, topicID string) (*pubsub.Topic, error) {
+	topic := client.Topic(topicID)
+	exists, err := topic.Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for topic %s: %w", topicID, err)
+	}
+	if exists {
+		return topic, nil
+	}
+	topic, err = client.CreateTopic(ctx, topicID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create topic %s: %w", topicID, err)
+	}
+	return topic, nil
+}
+
+// updateDeadLetterPolicy configures the subscription to forward messages that
+// have failed the max attempts to the topic.
+func updateDeadLetterPolicy(ctx context.Context, sub *pubsub.Subscription, topic string, maxAttempts int) error {
+	if !strings.HasPrefix(topic, "projects/") {
+		topic = fmt.Sprintf("projects/%s/topics/%s", viper.GetString(projectIDFlag), topic)
+	}
+	_, err := sub.Update(ctx, pubsub.SubscriptionConfigToUpdate{
+		DeadLetterPolicy: &pubsub.DeadLetterPolicy{
+			DeadLetterTopic:     topic,
+			MaxDeliveryAttempts: maxAttempts,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure the dead-letter topic %s: %w", topic, err)
+	}
+	return nil
+}
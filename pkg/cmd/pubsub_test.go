@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/gitops-tools/pkg/client/mock"
+	"github.com/gitops-tools/pkg/updater"
+	"github.com/go-logr/zapr"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/gitops-tools/image-updater/pkg/applier"
+	"github.com/gitops-tools/image-updater/pkg/config"
+)
+
+const (
+	testProjectID  = "test-project"
+	testGcrRepo    = "gcr.io/mynamespace/repository"
+	testGitHubRepo = "testorg/testrepo"
+	testFilePath   = "environments/test/services/service-a/test.yaml"
+)
+
+func TestReceiveFromViper(t *testing.T) {
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+	t.Setenv("PUBSUB_EMULATOR_HOST", srv.Addr)
+	setViper(t, map[string]interface{}{
+		projectIDFlag:          testProjectID,
+		subscriptionNameFlag:   "gcr-image-updater",
+		createSubscriptionFlag: true,
+		topicFlag:              "gcr",
+		maxOutstandingFlag:     1,
+		numGoroutinesFlag:      1,
+		maxExtensionFlag:       time.Minute,
+	})
+
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- receiveFromViper(ctx, logger, applier)
+	}()
+
+	id := publish(t, srv, "gcr", `{"action":"INSERT","digest":"gcr.io/mynamespace/repository@sha256:6ec128e26cd5da4f1f2f6d2bd0e0a4c6b8f1e9f3e5a9e7d4b1c2a3f4e5d6c7b8","tag":"gcr.io/mynamespace/repository:latest"}`)
+	waitForAck(t, srv, id)
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	want := "test:\n  image: gcr.io/mynamespace/repository:latest\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+	m.AssertPullRequestCreated(testGitHubRepo, &scm.PullRequestInput{
+		Body:  fmt.Sprintf("Automated update from %q", testGcrRepo),
+		Head:  "test-branch-a",
+		Base:  "master",
+		Title: "Automated image update",
+	})
+}
+
+func TestCreateSubscriptionFromViperWithMissingSubscription(t *testing.T) {
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+	t.Setenv("PUBSUB_EMULATOR_HOST", srv.Addr)
+	setViper(t, map[string]interface{}{
+		projectIDFlag:        testProjectID,
+		subscriptionNameFlag: "gcr-image-updater",
+	})
+
+	sub, err := createSubscriptionFromViper(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := sub.Exists(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("subscription was created")
+	}
+}
+
+// publish publishes the data to the topic, waiting until the topic exists.
+func publish(t *testing.T, srv *pstest.Server, topicID, data string) string {
+	t.Helper()
+	client, err := pubsub.NewClient(context.Background(), testProjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	topic := client.Topic(topicID)
+	defer topic.Stop()
+	poll(t, func() bool {
+		exists, err := topic.Exists(context.Background())
+		return err == nil && exists
+	})
+	id, err := topic.Publish(context.Background(), &pubsub.Message{Data: []byte(data)}).Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func waitForAck(t *testing.T, srv *pstest.Server, id string) {
+	t.Helper()
+	poll(t, func() bool {
+		msg := srv.Message(id)
+		return msg != nil && msg.Acks > 0
+	})
+}
+
+func poll(t *testing.T, f func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// setViper sets the values in viper, and resets them when the test finishes.
+func setViper(t *testing.T, values map[string]interface{}) {
+	t.Helper()
+	for k, v := range values {
+		viper.Set(k, v)
+	}
+	t.Cleanup(viper.Reset)
+}
+
+func createConfigs() *config.RepoConfiguration {
+	return &config.RepoConfiguration{
+		Repositories: []*config.Repository{
+			{
+				Name:               testGcrRepo,
+				SourceRepo:         testGitHubRepo,
+				SourceBranch:       "master",
+				FilePath:           testFilePath,
+				UpdateKey:          "test.image",
+				BranchGenerateName: "test-branch-",
+			},
+		},
+	}
+}
+
+type stubNameGenerator struct {
+	name string
+}
+
+func (s stubNameGenerator) PrefixedName(p string) string {
+	return p + s.name
+}
@@ -1,10 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"log"
+	"strings"
 
+	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/gitops-tools/image-updater/pkg/applier"
+	"github.com/gitops-tools/image-updater/pkg/config"
+	"github.com/gitops-tools/image-updater/pkg/retry"
 )
 
 const (
@@ -12,6 +19,14 @@ const (
 	apiEndpointFlag = "api-endpoint"
 	authTokenFlag   = "auth_token"
 	insecureFlag    = "insecure"
+
+	conflictRetriesFlag  = "conflict-retries"
+	gitRetriesFlag       = "git-retries"
+	gitRetryDelayFlag    = "git-retry-delay"
+	gitMaxRetryDelayFlag = "git-max-retry-delay"
+
+	watchConfigFlag     = "watch-config"
+	failOnUndefinedFlag = "fail-on-undefined-env"
 )
 
 func init() {
@@ -58,13 +73,51 @@ func makeRootCmd() *cobra.Command {
 	)
 	logIfError(viper.BindPFlag(insecureFlag, cmd.PersistentFlags().Lookup(insecureFlag)))
 
+	cmd.PersistentFlags().Int(
+		conflictRetriesFlag,
+		applier.DefaultConflictRetries,
+		"number of times to retry updating a file that was changed while it was being updated",
+	)
+	logIfError(viper.BindPFlag(conflictRetriesFlag, cmd.PersistentFlags().Lookup(conflictRetriesFlag)))
+
+	cmd.PersistentFlags().Int(
+		gitRetriesFlag,
+		retry.DefaultBackoff.Attempts,
+		"number of attempts for Git service requests that fail with transient errors e.g. 502 or rate limits",
+	)
+	logIfError(viper.BindPFlag(gitRetriesFlag, cmd.PersistentFlags().Lookup(gitRetriesFlag)))
+
+	cmd.PersistentFlags().Duration(
+		gitRetryDelayFlag,
+		retry.DefaultBackoff.Initial,
+		"delay before retrying Git service requests, doubled for each retry",
+	)
+	logIfError(viper.BindPFlag(gitRetryDelayFlag, cmd.PersistentFlags().Lookup(gitRetryDelayFlag)))
+
+	cmd.PersistentFlags().Duration(
+		gitMaxRetryDelayFlag,
+		retry.DefaultBackoff.Max,
+		"maximum delay before retrying Git service requests, longer delays requested by the Git service fail the update",
+	)
+	logIfError(viper.BindPFlag(gitMaxRetryDelayFlag, cmd.PersistentFlags().Lookup(gitMaxRetryDelayFlag)))
+
+	cmd.PersistentFlags().Bool(
+		failOnUndefinedFlag,
+		false,
+		"fail to load the repository configuration if it refers to environment variables that aren't set",
+	)
+	logIfError(viper.BindPFlag(failOnUndefinedFlag, cmd.PersistentFlags().Lookup(failOnUndefinedFlag)))
+
 	cmd.AddCommand(makeHTTPCmd())
 	cmd.AddCommand(makeUpdateCmd())
 	cmd.AddCommand(makePubsubCmd())
+	cmd.AddCommand(makeValidateCmd())
+	cmd.AddCommand(makeSchemaCmd())
 	return cmd
 }
 
 func initConfig() {
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
 }
 
@@ -74,3 +127,36 @@ func Execute() {
 		log.Fatal(err)
 	}
 }
+
+// configureApplier configures the applier with the retry options.
+func configureApplier(a *applier.Applier) *applier.Applier {
+	a.SetConflictRetries(viper.GetInt(conflictRetriesFlag))
+	a.SetBackoff(retry.Backoff{
+		Attempts: viper.GetInt(gitRetriesFlag),
+		Initial:  viper.GetDuration(gitRetryDelayFlag),
+		Max:      viper.GetDuration(gitMaxRetryDelayFlag),
+	})
+	return a
+}
+
+// watchConfig reloads the configuration of the applier when the configuration
+// file changes, until the context is cancelled.
+func watchConfig(ctx context.Context, logger logr.Logger, a *applier.Applier) {
+	if !viper.GetBool(watchConfigFlag) {
+		return
+	}
+	go func() {
+		if err := config.Watch(ctx, logger, viper.GetString("config"), a.SetConfig, configOptions()...); err != nil {
+			logger.Error(err, "failed to watch config, changes will not be reloaded")
+		}
+	}()
+}
+
+// configOptions returns the options for loading the repository configuration.
+func configOptions() []config.Option {
+	var opts []config.Option
+	if viper.GetBool(failOnUndefinedFlag) {
+		opts = append(opts, config.FailOnUndefined())
+	}
+	return opts
+}
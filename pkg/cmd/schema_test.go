@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSchemaCmd(t *testing.T) {
+	out := &bytes.Buffer{}
+	cmd := makeSchemaCmd()
+	cmd.SetOut(out)
+	cmd.SetArgs(nil)
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]struct {
+				Enum []string `json:"enum"`
+			} `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &schema); err != nil {
+		t.Fatalf("failed to parse schema: %s", err)
+	}
+	// The parsers in the schema must be the parsers that the commands accept.
+	parsers := schema.Defs["Hook"].Properties["parser"].Enum
+	if len(parsers) == 0 {
+		t.Fatal("schema has no parsers")
+	}
+	for _, p := range parsers {
+		if _, err := parser(p); err != nil {
+			t.Errorf("schema parser %q is not accepted: %s", p, err)
+		}
+	}
+}
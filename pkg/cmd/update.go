@@ -27,7 +27,7 @@ func makeUpdateCmd() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to create a git driver: %s", err)
 			}
-			applier := applier.New(zapr.NewLogger(logger), client.New(scmClient), nil)
+			applier := configureApplier(applier.New(zapr.NewLogger(logger), client.New(scmClient), nil))
 			return applier.UpdateRepository(context.Background(), configFromFlags(), viper.GetString("new-image-url"))
 		},
 	}
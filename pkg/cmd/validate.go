@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gitops-tools/image-updater/pkg/config"
+)
+
+func makeValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "validate a repository configuration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := cmd.Flags().GetString("config")
+			if err != nil {
+				return err
+			}
+			if err := validateConfig(path); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s is valid\n", path)
+			return nil
+		},
+	}
+
+	// The flag isn't bound to viper, the "config" key is bound to the flags
+	// of the http and pubsub commands.
+	cmd.Flags().String(
+		"config",
+		"/etc/image-updater/config.yaml",
+		"repository configuration to validate",
+	)
+
+	return cmd
+}
+
+// validateConfig loads and validates the configuration, and checks that the
+// parsers of the hooks are known.
+func validateConfig(path string) error {
+	cfg, err := config.Load(path, configOptions()...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	var errs []error
+	for i, h := range cfg.Hooks {
+		if _, err := parser(h.Parser); err != nil {
+			errs = append(errs, fmt.Errorf("hooks[%d]: %w", i, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: invalid configuration: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gitops-tools/image-updater/test"
+)
+
+func TestValidateCmd(t *testing.T) {
+	validateTests := []struct {
+		name    string
+		config  string
+		want    string
+		wantErr string
+	}{
+		{
+			"valid config",
+			`hooks:
+  - path: /hooks/quay
+    parser: quay
+repositories:
+  - name: testing/repo-image
+    sourceRepo: example/example-source
+    sourceBranch: main
+    filePath: test/file.yaml
+    updateKey: person.name
+`,
+			"config.yaml is valid\n",
+			"",
+		},
+		{
+			"invalid repository",
+			`repositories:
+  - name: testing/repo-image
+    sourceBranch: main
+    filePath: test/file.yaml
+    updateKey: person.name
+    tagMatch: "["
+`,
+			"",
+			`config.yaml: invalid configuration: repositories\[0\] \(testing/repo-image\): sourceRepo is required
+repositories\[0\] \(testing/repo-image\): failed to compile TagMatch regular expression`,
+		},
+		{
+			"unknown parser",
+			`hooks:
+  - path: /hooks/quay
+    parser: quay
+  - path: /hooks/acme
+    parser: acme
+repositories: []
+`,
+			"",
+			`config.yaml: invalid configuration: hooks\[1\]: unknown parser: acme$`,
+		},
+	}
+
+	for _, tt := range validateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			out := &bytes.Buffer{}
+			cmd := makeValidateCmd()
+			cmd.SetOut(out)
+			cmd.SetErr(out)
+			cmd.SetArgs([]string{"--config", path})
+
+			err := cmd.Execute()
+
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if tt.want != "" && out.String() != filepath.Join(filepath.Dir(path), tt.want) {
+				t.Fatalf("got output %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -4,46 +4,212 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"regexp"
 
-	"sigs.k8s.io/yaml"
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/gitops-tools/image-updater/pkg/auth"
 )
 
 // Repository is the items that are required to update a specific file in a repo.
+//
+// Repositories match images by the exact Name, or by the NameMatch regular
+// expression or NameGlob pattern, the capture groups from the patterns can be
+// used in the FilePath, UpdateKey, BranchGenerateName and Targets e.g. $1.
 type Repository struct {
-	Name               string `json:"name"`
-	SourceRepo         string `json:"sourceRepo"`
-	SourceBranch       string `json:"sourceBranch"`
-	FilePath           string `json:"filePath"`
-	UpdateKey          string `json:"updateKey"`
-	BranchGenerateName string `json:"branchGenerateName"`
-	TagMatch           string `json:"tagMatch"`
+	Name               string     `json:"name,omitempty"`
+	NameMatch          string     `json:"nameMatch,omitempty"`
+	NameGlob           string     `json:"nameGlob,omitempty"`
+	SourceRepo         string     `json:"sourceRepo"`
+	SourceBranch       string     `json:"sourceBranch"`
+	FilePath           string     `json:"filePath"`
+	UpdateKey          string     `json:"updateKey"`
+	BranchGenerateName string     `json:"branchGenerateName"`
+	TagMatch           string     `json:"tagMatch"`
+	TagPolicy          *TagPolicy `json:"tagPolicy,omitempty"`
+	PinDigest          string     `json:"pinDigest,omitempty" jsonschema:"enum=withTag,enum=digestOnly"`
+	Targets            []Target   `json:"targets,omitempty"`
+
+	// The regular expressions, and the semver constraint of the TagPolicy,
+	// are compiled when the configuration is validated.
+	nameRE            *regexp.Regexp
+	tagMatchRE        *regexp.Regexp
+	semverConstraints *semver.Constraints
+
+	// The file that the repository was included from, and its index in the
+	// file, these identify the repository in errors.
+	source string
+	index  int
+}
+
+// Defaults are the values for fields that repositories don't set.
+type Defaults struct {
+	SourceRepo         string     `json:"sourceRepo,omitempty"`
+	SourceBranch       string     `json:"sourceBranch,omitempty"`
+	BranchGenerateName string     `json:"branchGenerateName,omitempty"`
+	TagMatch           string     `json:"tagMatch,omitempty"`
+	TagPolicy          *TagPolicy `json:"tagPolicy,omitempty"`
+	PinDigest          string     `json:"pinDigest,omitempty" jsonschema:"enum=withTag,enum=digestOnly"`
+}
+
+func (d *Defaults) apply(r *Repository) {
+	if r.SourceRepo == "" {
+		r.SourceRepo = d.SourceRepo
+	}
+	if r.SourceBranch == "" {
+		r.SourceBranch = d.SourceBranch
+	}
+	if r.BranchGenerateName == "" {
+		r.BranchGenerateName = d.BranchGenerateName
+	}
+	if r.TagMatch == "" {
+		r.TagMatch = d.TagMatch
+	}
+	if r.TagPolicy == nil {
+		r.TagPolicy = d.TagPolicy
+	}
+	if r.PinDigest == "" {
+		r.PinDigest = d.PinDigest
+	}
+}
+
+// TagMatchRegexp returns the compiled TagMatch regular expression, or nil if
+// there is no TagMatch.
+func (r Repository) TagMatchRegexp() (*regexp.Regexp, error) {
+	if r.tagMatchRE != nil || r.TagMatch == "" {
+		return r.tagMatchRE, nil
+	}
+	re, err := regexp.Compile(r.TagMatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile TagMatch regular expression: %w", err)
+	}
+	return re, nil
+}
+
+// SemverConstraints returns the compiled semver constraint of the TagPolicy,
+// or nil if there is no constraint.
+func (r Repository) SemverConstraints() (*semver.Constraints, error) {
+	if r.semverConstraints != nil || r.TagPolicy == nil || r.TagPolicy.Semver == "" {
+		return r.semverConstraints, nil
+	}
+	c, err := semver.NewConstraint(r.TagPolicy.Semver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse semver constraint %q: %w", r.TagPolicy.Semver, err)
+	}
+	return c, nil
+}
+
+// Target is a key in a file that is updated with the new image.
+type Target struct {
+	FilePath  string `json:"filePath" jsonschema:"required"`
+	UpdateKey string `json:"updateKey" jsonschema:"required"`
+}
+
+// AllTargets returns the FilePath and UpdateKey, if they are set, followed by
+// the Targets.
+//
+// All the targets are updated in the same branch.
+func (r Repository) AllTargets() []Target {
+	var targets []Target
+	if r.FilePath != "" || r.UpdateKey != "" {
+		targets = append(targets, Target{FilePath: r.FilePath, UpdateKey: r.UpdateKey})
+	}
+	return append(targets, r.Targets...)
+}
+
+// FilePaths returns the paths of the files that are updated by the targets, in
+// the order they are first updated.
+func (r Repository) FilePaths() []string {
+	var paths []string
+	seen := map[string]bool{}
+	for _, t := range r.AllTargets() {
+		if !seen[t.FilePath] {
+			seen[t.FilePath] = true
+			paths = append(paths, t.FilePath)
+		}
+	}
+	return paths
+}
+
+const (
+	// PinDigestWithTag updates the file with the tag and digest of the
+	// pushed image e.g. quay.io/org/image:v1.2.0@sha256:...
+	PinDigestWithTag = "withTag"
+
+	// PinDigestOnly updates the file with only the digest of the pushed
+	// image e.g. quay.io/org/image@sha256:...
+	PinDigestOnly = "digestOnly"
+)
+
+// TagPolicy restricts the tags that update a repository using semantic
+// versioning.
+type TagPolicy struct {
+	// Semver is a constraint that tags must satisfy e.g. ">=1.2 <2.0".
+	Semver string `json:"semver,omitempty"`
+
+	// Prerelease allows tags with prerelease versions e.g. v1.2.0-rc1, these
+	// are excluded by default.
+	Prerelease bool `json:"prerelease,omitempty"`
+
+	// NeverDowngrade skips updates where the tag is older than the tag of
+	// the image at the UpdateKey in the file.
+	NeverDowngrade bool `json:"neverDowngrade,omitempty"`
 }
 
 // Parse reads and returns a configuration from Reader.
-func Parse(in io.Reader) (*RepoConfiguration, error) {
+//
+// Unknown fields are rejected, environment variables and file references in
+// string fields are replaced, files that are included are loaded relative to
+// the working directory, the defaults are applied, and the configuration is
+// validated.
+func Parse(in io.Reader, opts ...Option) (*RepoConfiguration, error) {
 	body, err := ioutil.ReadAll(in)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read YAML: %w", err)
 	}
-	rc := &RepoConfiguration{}
-	err = yaml.Unmarshal(body, rc)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
-	}
-	return rc, nil
+	return parseConfig(body, "", makeOptions(opts))
+}
+
+// Hook configures a path in the http service that receives hooks in a
+// specific format.
+type Hook struct {
+	Path   string       `json:"path" jsonschema:"required,pattern=^/"`
+	Parser string       `json:"parser" jsonschema:"required,enum=quay,enum=docker,enum=ghcr,enum=harbor,enum=distribution,enum=auto"`
+	Auth   *auth.Config `json:"auth,omitempty"`
 }
 
-// RepoConfiguration is a slice of Repository values.
+// RepoConfiguration is a slice of Repository values, and the optional hooks
+// that the http service receives.
+//
+// The Defaults are applied to the repositories that don't set the fields,
+// and the repositories from the files and directories in Include are added
+// to the Repositories.
 type RepoConfiguration struct {
+	Hooks        []*Hook       `json:"hooks,omitempty"`
+	Defaults     *Defaults     `json:"defaults,omitempty"`
+	Include      []string      `json:"include,omitempty"`
 	Repositories []*Repository `json:"repositories"`
+
+	// included is the paths of the files and directories that were included,
+	// including those included by included files.
+	included []string
 }
 
-// Find looks up the repository by name.
-func (c RepoConfiguration) Find(name string) *Repository {
+// Find looks up the repositories by name, the same image can update several
+// files and repositories.
+//
+// Repositories that match with a pattern are returned with the capture groups
+// substituted.
+func (c RepoConfiguration) Find(name string) ([]*Repository, error) {
+	var found []*Repository
 	for _, cfg := range c.Repositories {
-		if cfg.Name == name {
-			return cfg
+		m, err := cfg.match(name)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			found = append(found, m)
 		}
 	}
-	return nil
+	return found, nil
 }
@@ -6,31 +6,85 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/gitops-tools/image-updater/pkg/auth"
 )
 
 func TestRepoConfigurationFind(t *testing.T) {
 	findTests := []struct {
 		name string
-		want *Repository
+		want []*Repository
 	}{
-		{"testing", &Repository{Name: "testing"}},
+		{"testing", []*Repository{{Name: "testing", FilePath: "staging.yaml"}, {Name: "testing", FilePath: "production.yaml"}}},
+		{"another", []*Repository{{Name: "another"}}},
 		{"unknown", nil},
 	}
 
 	cfgs := RepoConfiguration{
 		Repositories: []*Repository{
-			{Name: "testing"},
+			{Name: "testing", FilePath: "staging.yaml"},
 			{Name: "another"},
+			{Name: "testing", FilePath: "production.yaml"},
 		},
 	}
 
 	for _, tt := range findTests {
-		if diff := cmp.Diff(tt.want, cfgs.Find(tt.name)); diff != "" {
+		found, err := cfgs.Find(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.want, found, cmpopts.IgnoreUnexported(Repository{})); diff != "" {
 			t.Errorf("Find(%s) failed:\n %s", tt.name, diff)
 		}
 	}
 }
 
+func TestRepositoryAllTargets(t *testing.T) {
+	targetTests := []struct {
+		name      string
+		repo      Repository
+		want      []Target
+		wantPaths []string
+	}{
+		{"no targets", Repository{}, nil, nil},
+		{
+			"file path and update key",
+			Repository{FilePath: "deploy.yaml", UpdateKey: "spec.image"},
+			[]Target{{FilePath: "deploy.yaml", UpdateKey: "spec.image"}},
+			[]string{"deploy.yaml"},
+		},
+		{
+			"file path and targets",
+			Repository{
+				FilePath:  "deploy.yaml",
+				UpdateKey: "spec.image",
+				Targets: []Target{
+					{FilePath: "deploy.yaml", UpdateKey: "spec.initImage"},
+					{FilePath: "cronjob.yaml", UpdateKey: "spec.image"},
+				},
+			},
+			[]Target{
+				{FilePath: "deploy.yaml", UpdateKey: "spec.image"},
+				{FilePath: "deploy.yaml", UpdateKey: "spec.initImage"},
+				{FilePath: "cronjob.yaml", UpdateKey: "spec.image"},
+			},
+			[]string{"deploy.yaml", "cronjob.yaml"},
+		},
+	}
+
+	for _, tt := range targetTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, tt.repo.AllTargets()); diff != "" {
+				t.Errorf("AllTargets() failed:\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantPaths, tt.repo.FilePaths()); diff != "" {
+				t.Errorf("FilePaths() failed:\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestParse(t *testing.T) {
 	parseTests := []struct {
 		filename string
@@ -51,6 +105,40 @@ func TestParse(t *testing.T) {
 				},
 			},
 		},
+		{
+			"testdata/config_with_hooks.yaml", &RepoConfiguration{
+				Hooks: []*Hook{
+					{Path: "/hooks/quay", Parser: "quay"},
+					{Path: "/hooks/harbor", Parser: "harbor", Auth: &auth.Config{Secret: "my-harbor-secret"}},
+				},
+				Repositories: []*Repository{
+					{
+						Name:               "testing/repo-image",
+						SourceRepo:         "example/example-source",
+						SourceBranch:       "main",
+						FilePath:           "test/file.yaml",
+						UpdateKey:          "person.name",
+						BranchGenerateName: "repo-imager-",
+					},
+				},
+			},
+		},
+		{
+			"testdata/config_with_targets.yaml", &RepoConfiguration{
+				Repositories: []*Repository{
+					{
+						Name:               "testing/repo-image",
+						SourceRepo:         "example/example-source",
+						SourceBranch:       "main",
+						BranchGenerateName: "repo-imager-",
+						Targets: []Target{
+							{FilePath: "test/deployment.yaml", UpdateKey: "spec.template.spec.containers.0.image"},
+							{FilePath: "test/cronjob.yaml", UpdateKey: "spec.jobTemplate.spec.template.spec.containers.0.image"},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range parseTests {
@@ -66,7 +154,7 @@ func TestParse(t *testing.T) {
 				rt.Errorf("failed to parse %v: %s", tt.filename, err)
 				return
 			}
-			if diff := cmp.Diff(tt.want, got); diff != "" {
+			if diff := cmp.Diff(tt.want, got, cmpopts.IgnoreUnexported(RepoConfiguration{}, Repository{})); diff != "" {
 				rt.Errorf("Parse(%s) failed diff\n%s", tt.filename, diff)
 			}
 		})
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Load reads and returns the configuration from the file at path.
+//
+// Files that are included, and file references, are loaded relative to the
+// directory of the file.
+func Load(path string, opts ...Option) (*RepoConfiguration, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(body, path, makeOptions(opts))
+}
+
+// parseConfig parses and validates the configuration in body, which was read
+// from path, if path is empty, files are included relative to the working
+// directory.
+func parseConfig(body []byte, path string, o *options) (*RepoConfiguration, error) {
+	dir := "."
+	loading := map[string]bool{}
+	if path != "" {
+		dir = filepath.Dir(path)
+		if abs, err := filepath.Abs(path); err == nil {
+			loading[abs] = true
+		}
+	}
+	rc, err := decode(body, "", dir, loading, o)
+	if err != nil {
+		return nil, err
+	}
+	if err := rc.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return rc, nil
+}
+
+// decode decodes the configuration that was read from source, adds the
+// repositories from the files that it includes, and applies the defaults.
+//
+// The source is empty for the main configuration file.
+func decode(body []byte, source, dir string, loading map[string]bool, o *options) (*RepoConfiguration, error) {
+	rc := &RepoConfiguration{}
+	if err := yaml.UnmarshalStrict(body, rc); err != nil {
+		return nil, withSource(source, fmt.Errorf("failed to unmarshal YAML: %w", err))
+	}
+	if err := interpolate(rc, dir, o); err != nil {
+		return nil, withSource(source, err)
+	}
+	if source != "" && len(rc.Hooks) > 0 {
+		return nil, withSource(source, errors.New("hooks can only be configured in the main configuration file"))
+	}
+	for i, r := range rc.Repositories {
+		r.source = source
+		r.index = i
+	}
+
+	for _, include := range rc.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(dir, include)
+		}
+		files, err := includedFiles(include)
+		if err != nil {
+			return nil, withSource(source, err)
+		}
+		rc.included = append(rc.included, filepath.Clean(include))
+		for _, f := range files {
+			included, err := loadIncluded(f, loading, o)
+			if err != nil {
+				return nil, err
+			}
+			rc.Repositories = append(rc.Repositories, included.Repositories...)
+			rc.included = append(rc.included, included.included...)
+		}
+	}
+
+	if rc.Defaults != nil {
+		for _, r := range rc.Repositories {
+			rc.Defaults.apply(r)
+		}
+	}
+	return rc, nil
+}
+
+func loadIncluded(path string, loading map[string]bool, o *options) (*RepoConfiguration, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if loading[abs] {
+		return nil, fmt.Errorf("%s: included recursively", path)
+	}
+	loading[abs] = true
+	defer delete(loading, abs)
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to include: %w", err)
+	}
+	return decode(body, path, filepath.Dir(path), loading, o)
+}
+
+// includedFiles returns the path if it's a file, or the YAML files in the
+// directory, in name order.
+func includedFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to include: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to include: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		// Kubernetes mounts ConfigMaps with hidden directories, and symlinks
+		// to the files in them.
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		f := filepath.Join(path, e.Name())
+		if info, err := os.Stat(f); err == nil && info.Mode().IsRegular() {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+func withSource(source string, err error) error {
+	if source == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", source, err)
+}
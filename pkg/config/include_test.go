@@ -0,0 +1,196 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/gitops-tools/image-updater/test"
+)
+
+func TestLoadWithIncludes(t *testing.T) {
+	cfg, err := Load("testdata/config_with_includes.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Repository{
+		{
+			Name:               "testing/repo-image",
+			SourceRepo:         "example/example-source",
+			SourceBranch:       "main",
+			FilePath:           "test/file.yaml",
+			UpdateKey:          "person.name",
+			BranchGenerateName: "repo-imager-",
+		},
+		{
+			Name:               "team-a/service-a",
+			SourceRepo:         "team-a/deployments",
+			SourceBranch:       "main",
+			FilePath:           "service-a/deployment.yaml",
+			UpdateKey:          "spec.template.spec.containers.0.image",
+			BranchGenerateName: "repo-imager-",
+		},
+		{
+			Name:               "team-b/service-b",
+			SourceRepo:         "example/example-source",
+			SourceBranch:       "production",
+			FilePath:           "service-b/deployment.yaml",
+			UpdateKey:          "spec.template.spec.containers.0.image",
+			BranchGenerateName: "service-b-",
+		},
+	}
+	if diff := cmp.Diff(want, cfg.Repositories, cmpopts.IgnoreUnexported(Repository{})); diff != "" {
+		t.Fatalf("Load() failed:\n%s", diff)
+	}
+}
+
+func TestLoadWithDefaultTagPolicy(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `defaults:
+  sourceRepo: example/example-source
+  sourceBranch: main
+  tagPolicy:
+    semver: ">=1.0"
+  pinDigest: withTag
+repositories:
+  - name: testing/repo-image
+    filePath: test/file.yaml
+    updateKey: person.name
+  - name: testing/other-image
+    filePath: test/file.yaml
+    updateKey: person.image
+    tagPolicy:
+      prerelease: true
+    pinDigest: digestOnly
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Repository{
+		{
+			Name:         "testing/repo-image",
+			SourceRepo:   "example/example-source",
+			SourceBranch: "main",
+			FilePath:     "test/file.yaml",
+			UpdateKey:    "person.name",
+			TagPolicy:    &TagPolicy{Semver: ">=1.0"},
+			PinDigest:    PinDigestWithTag,
+		},
+		{
+			Name:         "testing/other-image",
+			SourceRepo:   "example/example-source",
+			SourceBranch: "main",
+			FilePath:     "test/file.yaml",
+			UpdateKey:    "person.image",
+			TagPolicy:    &TagPolicy{Prerelease: true},
+			PinDigest:    PinDigestOnly,
+		},
+	}
+	if diff := cmp.Diff(want, cfg.Repositories, cmpopts.IgnoreUnexported(Repository{})); diff != "" {
+		t.Fatalf("Load() failed:\n%s", diff)
+	}
+}
+
+func TestLoadWithInvalidIncludes(t *testing.T) {
+	includeTests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			"missing include",
+			map[string]string{"config.yaml": "include:\n  - teams\n"},
+			`failed to include: stat .*/teams: no such file or directory`,
+		},
+		{
+			"invalid YAML",
+			map[string]string{
+				"config.yaml":       "include:\n  - teams\n",
+				"teams/team-a.yaml": "repositories: [",
+			},
+			`^.*/teams/team-a.yaml: failed to unmarshal YAML`,
+		},
+		{
+			"unknown field",
+			map[string]string{
+				"config.yaml":       "include:\n  - teams\n",
+				"teams/team-a.yaml": "repositories:\n  - name: team-a/service-a\n    file: deploy.yaml\n",
+			},
+			`^.*/teams/team-a.yaml: failed to unmarshal YAML: .*unknown field "file"`,
+		},
+		{
+			"invalid repository",
+			map[string]string{
+				"config.yaml":       "defaults:\n  sourceBranch: main\ninclude:\n  - teams\n",
+				"teams/team-a.yaml": "defaults:\n  sourceRepo: team-a/deployments\nrepositories:\n  - name: team-a/service-a\n    filePath: deploy.yaml\n    updateKey: spec.image\n",
+				"teams/team-b.yaml": "repositories:\n  - name: team-b/service-a\n    filePath: deploy.yaml\n    updateKey: spec.image\n  - name: team-b/service-b\n    sourceRepo: team-b/deployments\n    filePath: deploy.yaml\n",
+			},
+			`^invalid configuration: .*/teams/team-b.yaml: repositories\[0\] \(team-b/service-a\): sourceRepo is required
+.*/teams/team-b.yaml: repositories\[1\] \(team-b/service-b\): updateKey is required$`,
+		},
+		{
+			"hooks in included files",
+			map[string]string{
+				"config.yaml": "include:\n  - team-a.yaml\n",
+				"team-a.yaml": "hooks:\n  - path: /hooks/quay\n    parser: quay\n",
+			},
+			`team-a.yaml: hooks can only be configured in the main configuration file`,
+		},
+		{
+			"recursive include",
+			map[string]string{
+				"config.yaml": "include:\n  - team-a.yaml\n",
+				"team-a.yaml": "include:\n  - config.yaml\n",
+			},
+			`config.yaml: included recursively`,
+		},
+	}
+
+	for _, tt := range includeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, body := range tt.files {
+				writeFile(t, filepath.Join(dir, name), body)
+			}
+
+			_, err := Load(filepath.Join(dir, "config.yaml"))
+
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIncludedFilesInConfigMap(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "..2026_01", "team-a.yaml"), "")
+	writeFile(t, filepath.Join(dir, "..2026_01", "README.md"), "")
+	symlink(t, "..2026_01", filepath.Join(dir, "..data"))
+	symlink(t, filepath.Join("..data", "team-a.yaml"), filepath.Join(dir, "team-a.yaml"))
+	symlink(t, filepath.Join("..data", "README.md"), filepath.Join(dir, "README.md"))
+
+	files, err := includedFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{filepath.Join(dir, "team-a.yaml")}, files); diff != "" {
+		t.Fatalf("includedFiles() failed:\n%s", diff)
+	}
+}
+
+func writeConfig(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
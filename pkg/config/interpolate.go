@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Option configures how the configuration is parsed.
+type Option func(*options)
+
+type options struct {
+	failOnUndefined bool
+
+	// watching is called when Watch has started watching the files, so that
+	// tests can wait until changes are detected.
+	watching func()
+}
+
+// FailOnUndefined makes parsing fail if a string field refers to an
+// environment variable that isn't set, by default, the reference is left
+// unchanged.
+func FailOnUndefined() Option {
+	return func(o *options) {
+		o.failOnUndefined = true
+	}
+}
+
+func makeOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// interpolate replaces references to environment variables e.g. ${VAR} in
+// the string fields of the configuration with their values, and fields with
+// a value of file:<path> with the contents of the file.
+//
+// Relative file paths are relative to dir.
+func interpolate(rc *RepoConfiguration, dir string, o *options) error {
+	e := expander{dir: dir, failOnUndefined: o.failOnUndefined}
+	return e.value(reflect.ValueOf(rc).Elem(), "")
+}
+
+type expander struct {
+	dir             string
+	failOnUndefined bool
+
+	// groups is the capture groups of the pattern of the repository that is
+	// being expanded.
+	groups map[string]bool
+
+	// captures is the capture groups that can be referred to in the field
+	// that is being expanded, references to them are left unchanged, so that
+	// they're substituted when the repository matches.
+	captures map[string]bool
+}
+
+// capturedFields is the fields of repositories and targets that capture
+// groups are substituted into.
+var capturedFields = map[string]bool{
+	"filePath":           true,
+	"updateKey":          true,
+	"branchGenerateName": true,
+}
+
+// captureGroups returns the names and numbers of the capture groups in the
+// pattern of the repository, or nil if it matches by exact name, or the
+// pattern is invalid, which is reported by validation.
+func captureGroups(r *Repository) map[string]bool {
+	re, err := r.matcher()
+	if err != nil || re == nil {
+		return nil
+	}
+	groups := map[string]bool{}
+	for i, name := range re.SubexpNames() {
+		groups[strconv.Itoa(i)] = true
+		if name != "" {
+			groups[name] = true
+		}
+	}
+	return groups
+}
+
+func (e expander) value(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return e.value(v.Elem(), path)
+	case reflect.Struct:
+		t := v.Type()
+		groups := e.groups
+		if r, ok := v.Addr().Interface().(*Repository); ok {
+			groups = captureGroups(r)
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" {
+				name = f.Name
+			}
+			fe := expander{dir: e.dir, failOnUndefined: e.failOnUndefined, groups: groups}
+			if capturedFields[name] {
+				fe.captures = groups
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := fe.value(v.Field(i), name); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := e.value(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		s, err := e.expand(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.SetString(s)
+	}
+	return nil
+}
+
+// expand replaces the environment variables in s, and if the result is a
+// file: reference, returns the contents of the file, without trailing
+// newlines.
+func (e expander) expand(s string) (string, error) {
+	s, err := e.expandEnv(s)
+	if err != nil {
+		return "", err
+	}
+	filename, ok := strings.CutPrefix(s, "file:")
+	if !ok {
+		return s, nil
+	}
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(e.dir, filename)
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file reference: %w", err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// expandEnv replaces ${VAR} with the value of the environment variable, $${
+// is replaced with a literal ${.
+//
+// Only the ${VAR} form is replaced, so that $ in regular expressions and
+// capture group references e.g. $1 are left unchanged, references to the
+// capture groups in the field e.g. ${app} are also left unchanged.
+func (e expander) expandEnv(s string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i == -1 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.Index(s[i:], "}")
+		if end == -1 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		name := s[i+2 : i+end]
+		b.WriteString(s[:i])
+		value, ok := os.LookupEnv(name)
+		switch {
+		case e.captures[name]:
+			b.WriteString(s[i : i+end+1])
+		case ok:
+			b.WriteString(value)
+		case e.failOnUndefined:
+			return "", fmt.Errorf("undefined environment variable %q", name)
+		default:
+			b.WriteString(s[i : i+end+1])
+		}
+		s = s[i+end+1:]
+	}
+}
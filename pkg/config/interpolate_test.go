@@ -0,0 +1,182 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/gitops-tools/image-updater/pkg/auth"
+	"github.com/gitops-tools/image-updater/test"
+)
+
+func TestLoadWithInterpolation(t *testing.T) {
+	t.Setenv("TEST_SOURCE_BRANCH", "staging")
+	t.Setenv("TEST_ENV", "test")
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "secrets", "webhook-secret"), "my-webhook-secret\n")
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, `hooks:
+  - path: /hooks/quay
+    parser: quay
+    auth:
+      secret: file:secrets/webhook-secret
+repositories:
+  - nameMatch: quay.io/acme/(?P<app>.*)
+    sourceRepo: example/example-source
+    sourceBranch: ${TEST_SOURCE_BRANCH}
+    filePath: ${TEST_ENV}/${app}/deployment.yaml
+    updateKey: spec.image
+    branchGenerateName: $${app}-
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &RepoConfiguration{
+		Hooks: []*Hook{
+			{Path: "/hooks/quay", Parser: "quay", Auth: &auth.Config{Secret: "my-webhook-secret"}},
+		},
+		Repositories: []*Repository{
+			{
+				NameMatch:          "quay.io/acme/(?P<app>.*)",
+				SourceRepo:         "example/example-source",
+				SourceBranch:       "staging",
+				FilePath:           "test/${app}/deployment.yaml",
+				UpdateKey:          "spec.image",
+				BranchGenerateName: "${app}-",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, cfg, cmpopts.IgnoreUnexported(RepoConfiguration{}, Repository{})); diff != "" {
+		t.Fatalf("Load() failed:\n%s", diff)
+	}
+}
+
+func TestParseWithInterpolationErrors(t *testing.T) {
+	t.Setenv("TEST_SOURCE_BRANCH", "staging")
+	interpolateTests := []struct {
+		name    string
+		config  string
+		opts    []Option
+		wantErr string
+	}{
+		{
+			"undefined variable",
+			"repositories:\n  - name: testing/repo-image\n    sourceRepo: ${TEST_UNDEFINED_REPO}\n    sourceBranch: main\n    filePath: test/file.yaml\n    updateKey: person.name\n",
+			[]Option{FailOnUndefined()},
+			`^repositories\[0\].sourceRepo: undefined environment variable "TEST_UNDEFINED_REPO"$`,
+		},
+		{
+			"undefined variable is left unchanged",
+			"repositories:\n  - name: testing/repo-image\n    sourceRepo: ${TEST_UNDEFINED_REPO}\n    sourceBranch: main\n    filePath: test/file.yaml\n    updateKey: person.name\n",
+			nil,
+			"",
+		},
+		{
+			"defined variable",
+			"repositories:\n  - name: testing/repo-image\n    sourceRepo: example/example-source\n    sourceBranch: ${TEST_SOURCE_BRANCH}\n    filePath: test/file.yaml\n    updateKey: person.name\n",
+			[]Option{FailOnUndefined()},
+			"",
+		},
+		{
+			"missing file",
+			"hooks:\n  - path: /hooks/quay\n    parser: quay\n    auth:\n      secret: file:/does/not/exist\n",
+			nil,
+			`^hooks\[0\].auth.secret: failed to read file reference: open /does/not/exist: no such file or directory$`,
+		},
+	}
+
+	for _, tt := range interpolateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.config), tt.opts...)
+
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadWithCaptureGroups(t *testing.T) {
+	t.Setenv("app", "wrong")
+	t.Setenv("TEST_SOURCE_REPO", "example/example-source")
+	cfg, err := Parse(strings.NewReader(`repositories:
+  - nameMatch: quay.io/acme/(?P<app>.*)
+    sourceRepo: ${TEST_SOURCE_REPO}
+    sourceBranch: main
+    filePath: apps/${app}/deployment.yaml
+    updateKey: spec.image
+    branchGenerateName: update-${1}-
+    targets:
+      - filePath: apps/${app}/cronjob.yaml
+        updateKey: spec.${app}.image
+  - nameGlob: quay.io/tools/*
+    sourceRepo: example/example-source
+    sourceBranch: main
+    filePath: tools/${1}/${app}.yaml
+    updateKey: spec.image
+`), FailOnUndefined())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Repository{
+		{
+			NameMatch:          "quay.io/acme/(?P<app>.*)",
+			SourceRepo:         "example/example-source",
+			SourceBranch:       "main",
+			FilePath:           "apps/${app}/deployment.yaml",
+			UpdateKey:          "spec.image",
+			BranchGenerateName: "update-${1}-",
+			Targets: []Target{
+				{FilePath: "apps/${app}/cronjob.yaml", UpdateKey: "spec.${app}.image"},
+			},
+		},
+		{
+			NameGlob:     "quay.io/tools/*",
+			SourceRepo:   "example/example-source",
+			SourceBranch: "main",
+			FilePath:     "tools/${1}/wrong.yaml",
+			UpdateKey:    "spec.image",
+		},
+	}
+	if diff := cmp.Diff(want, cfg.Repositories, cmpopts.IgnoreUnexported(Repository{})); diff != "" {
+		t.Fatalf("Parse() failed:\n%s", diff)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("TEST_VALUE", "value")
+	expandTests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"no variables", "no variables"},
+		{"${TEST_VALUE}", "value"},
+		{"prefix-${TEST_VALUE}-${TEST_VALUE}-suffix", "prefix-value-value-suffix"},
+		{"$${TEST_VALUE}", "${TEST_VALUE}"},
+		{"$$${TEST_VALUE}", "$${TEST_VALUE}"},
+		{"^v1$", "^v1$"},
+		{"apps/$1/deployment.yaml", "apps/$1/deployment.yaml"},
+		{"${TEST_UNDEFINED}", "${TEST_UNDEFINED}"},
+		{"${TEST_VALUE", "${TEST_VALUE"},
+	}
+
+	for _, tt := range expandTests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := expander{}.expandEnv(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("expandEnv(%q) got %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
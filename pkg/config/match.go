@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// matcher returns the regular expression that matches image names for the
+// repository, or nil if the repository matches by exact Name.
+func (r Repository) matcher() (*regexp.Regexp, error) {
+	switch {
+	case r.nameRE != nil:
+		return r.nameRE, nil
+	case r.NameMatch != "":
+		re, err := regexp.Compile("^(?:" + r.NameMatch + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile nameMatch regular expression: %w", err)
+		}
+		return re, nil
+	case r.NameGlob != "":
+		return globToRegexp(r.NameGlob)
+	}
+	return nil, nil
+}
+
+// match returns the repository for the image name if it matches.
+//
+// If the repository matches with a pattern, a copy is returned with the
+// capture groups from the match substituted into the FilePath, UpdateKey,
+// BranchGenerateName and Targets, and the Name set to the image name.
+func (r *Repository) match(name string) (*Repository, error) {
+	re, err := r.matcher()
+	if err != nil {
+		return nil, err
+	}
+	if re == nil {
+		if r.Name == name {
+			return r, nil
+		}
+		return nil, nil
+	}
+	submatches := re.FindStringSubmatchIndex(name)
+	if submatches == nil {
+		return nil, nil
+	}
+	expand := func(s string) string {
+		return string(re.ExpandString(nil, s, name, submatches))
+	}
+	expanded := *r
+	expanded.Name = name
+	expanded.FilePath = expand(r.FilePath)
+	expanded.UpdateKey = expand(r.UpdateKey)
+	expanded.BranchGenerateName = expand(r.BranchGenerateName)
+	expanded.Targets = nil
+	for _, t := range r.Targets {
+		expanded.Targets = append(expanded.Targets, Target{FilePath: expand(t.FilePath), UpdateKey: expand(t.UpdateKey)})
+	}
+	return &expanded, nil
+}
+
+// captureReferences returns the names of the capture groups that s refers
+// to, parsed the way that regexp.ExpandString parses them, so $1x refers to
+// the group named "1x", and $$ is a literal $.
+func captureReferences(s string) []string {
+	var names []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i+1 == len(s) {
+			break
+		}
+		s = s[i+1:]
+		if s[0] == '$' {
+			s = s[1:]
+			continue
+		}
+		braced := s[0] == '{'
+		if braced {
+			s = s[1:]
+		}
+		n := 0
+		for n < len(s) && isNameByte(s[n]) {
+			n++
+		}
+		if n == 0 || (braced && (n == len(s) || s[n] != '}')) {
+			continue
+		}
+		names = append(names, s[:n])
+		s = s[n:]
+		if braced {
+			s = s[1:]
+		}
+	}
+	return names
+}
+
+// knownGroup returns true if name is the number or name of a capture group
+// in re.
+func knownGroup(re *regexp.Regexp, name string) bool {
+	if n, err := strconv.Atoi(name); err == nil && strings.Trim(name, "0123456789") == "" {
+		return n <= re.NumSubexp()
+	}
+	return re.SubexpIndex(name) >= 0
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// globToRegexp converts a glob pattern to a regular expression, each wildcard
+// in the glob is a capture group.
+//
+// "*" matches any characters except "/", and "**" matches any characters.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		if glob[i] != '*' {
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			continue
+		}
+		if i+1 < len(glob) && glob[i+1] == '*' {
+			b.WriteString("(.*)")
+			i++
+			continue
+		}
+		b.WriteString("([^/]*)")
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile nameGlob %q: %w", glob, err)
+	}
+	return re, nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/gitops-tools/image-updater/test"
+)
+
+func TestRepoConfigurationFindWithPatterns(t *testing.T) {
+	findTests := []struct {
+		desc    string
+		repo    *Repository
+		name    string
+		want    []*Repository
+		wantErr string
+	}{
+		{
+			"regular expression",
+			&Repository{NameMatch: `quay.io/acme/(.*)`, FilePath: "apps/$1/deployment.yaml", UpdateKey: "spec.image", BranchGenerateName: "update-$1-"},
+			"quay.io/acme/service-a",
+			[]*Repository{{Name: "quay.io/acme/service-a", NameMatch: `quay.io/acme/(.*)`, FilePath: "apps/service-a/deployment.yaml", UpdateKey: "spec.image", BranchGenerateName: "update-service-a-"}},
+			"",
+		},
+		{
+			"regular expression with named groups",
+			&Repository{NameMatch: `quay.io/(?P<org>[^/]+)/(?P<app>.*)`, FilePath: "${org}/${app}.yaml", Targets: []Target{{FilePath: "${app}/cronjob.yaml", UpdateKey: "${app}.image"}}},
+			"quay.io/acme/service-a",
+			[]*Repository{
+				{
+					Name:      "quay.io/acme/service-a",
+					NameMatch: `quay.io/(?P<org>[^/]+)/(?P<app>.*)`,
+					FilePath:  "acme/service-a.yaml",
+					Targets:   []Target{{FilePath: "service-a/cronjob.yaml", UpdateKey: "service-a.image"}},
+				},
+			},
+			"",
+		},
+		{
+			"regular expression is anchored",
+			&Repository{NameMatch: `acme/(.*)`},
+			"quay.io/acme/service-a",
+			nil,
+			"",
+		},
+		{
+			"glob",
+			&Repository{NameGlob: "quay.io/*/*", FilePath: "$1/apps/$2/deployment.yaml"},
+			"quay.io/acme/service-a",
+			[]*Repository{{Name: "quay.io/acme/service-a", NameGlob: "quay.io/*/*", FilePath: "acme/apps/service-a/deployment.yaml"}},
+			"",
+		},
+		{
+			"glob doesn't match separators",
+			&Repository{NameGlob: "quay.io/*", FilePath: "$1.yaml"},
+			"quay.io/acme/service-a",
+			nil,
+			"",
+		},
+		{
+			"glob with double wildcard",
+			&Repository{NameGlob: "quay.io/**", FilePath: "$1.yaml"},
+			"quay.io/acme/service-a",
+			[]*Repository{{Name: "quay.io/acme/service-a", NameGlob: "quay.io/**", FilePath: "acme/service-a.yaml"}},
+			"",
+		},
+		{
+			"invalid regular expression",
+			&Repository{NameMatch: `quay.io/(`},
+			"quay.io/acme/service-a",
+			nil,
+			"failed to compile nameMatch",
+		},
+	}
+
+	for _, tt := range findTests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfgs := RepoConfiguration{Repositories: []*Repository{tt.repo}}
+
+			found, err := cfgs.Find(tt.name)
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, found, cmpopts.IgnoreUnexported(Repository{})); diff != "" {
+				t.Errorf("Find(%s) failed:\n%s", tt.name, diff)
+			}
+		})
+	}
+}
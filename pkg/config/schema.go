@@ -0,0 +1,31 @@
+package config
+
+import (
+	"encoding/json"
+
+	"github.com/invopop/jsonschema"
+)
+
+// SchemaID is the ID of the JSON Schema for the configuration file.
+const SchemaID = "https://github.com/gitops-tools/image-updater/config.schema.json"
+
+// Schema returns the JSON Schema for the configuration file, generated from
+// the RepoConfiguration type.
+//
+// Unknown fields are rejected, as they are when parsing the configuration.
+func Schema() ([]byte, error) {
+	r := &jsonschema.Reflector{
+		RequiredFromJSONSchemaTags: true,
+	}
+	s := r.Reflect(&RepoConfiguration{})
+	s.ID = SchemaID
+	s.Title = "image-updater configuration"
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// JSONSchemaExtend requires exactly one of the ways to match images.
+func (Repository) JSONSchemaExtend(s *jsonschema.Schema) {
+	for _, name := range []string{"name", "nameMatch", "nameGlob"} {
+		s.OneOf = append(s.OneOf, &jsonschema.Schema{Required: []string{name}})
+	}
+}
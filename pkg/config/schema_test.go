@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gitops-tools/image-updater/test"
+)
+
+func TestSchemaWithValidConfigs(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, "../../example/config.yaml")
+	// The included files depend on the defaults in the including file, so
+	// they aren't valid on their own.
+	included, err := filepath.Glob("testdata/teams/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := compileSchema(t)
+
+	for _, filename := range append(files, included...) {
+		t.Run(filename, func(t *testing.T) {
+			b, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := schema.Validate(yamlToValue(t, b)); err != nil {
+				t.Fatalf("%s failed schema validation: %#v", filename, err)
+			}
+		})
+	}
+	for _, filename := range files {
+		if _, err := Load(filename); err != nil {
+			t.Fatalf("failed to load %s: %s", filename, err)
+		}
+	}
+}
+
+func TestSchemaWithInvalidConfigs(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			"unknown field",
+			"repositories:\n  - name: testing/repo-image\n    sourceRepo: example/example-source\n    sourceBranch: main\n    fileName: test/file.yaml\n",
+			"additionalProperties 'fileName' not allowed",
+		},
+		{
+			"missing target updateKey",
+			"repositories:\n  - name: testing/repo-image\n    targets:\n      - filePath: test/file.yaml\n",
+			"missing properties: 'updateKey'",
+		},
+		{
+			"multiple names",
+			"repositories:\n  - name: testing/repo-image\n    nameGlob: testing/*\n    sourceRepo: example/example-source\n    sourceBranch: main\n",
+			"valid against schemas at indexes 0 and 2",
+		},
+		{
+			"invalid pinDigest",
+			"repositories:\n  - name: testing/repo-image\n    sourceRepo: example/example-source\n    sourceBranch: main\n    pinDigest: always\n",
+			"value must be one of",
+		},
+		{
+			"unknown parser",
+			"hooks:\n  - path: /hooks/acme\n    parser: acme\n",
+			"value must be one of",
+		},
+	}
+
+	schema := compileSchema(t)
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(yamlToValue(t, []byte(tt.config)))
+
+			// The detailed output includes the causes of the error.
+			if verr, ok := err.(*jsonschema.ValidationError); ok {
+				err = fmt.Errorf("%#v", verr)
+			}
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func compileSchema(t *testing.T) *jsonschema.Schema {
+	t.Helper()
+	b, err := Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(SchemaID, bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.Compile(SchemaID)
+	if err != nil {
+		t.Fatalf("failed to compile schema: %s", err)
+	}
+	return s
+}
+
+func yamlToValue(t *testing.T, b []byte) interface{} {
+	t.Helper()
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(j, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
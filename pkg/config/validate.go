@@ -0,0 +1,195 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Validate checks the configuration and returns all the problems that it
+// finds, each identified by the index of the entry.
+//
+// The regular expressions and semver constraints in the repositories are
+// compiled and stored, so that they aren't compiled for each hook.
+func (c *RepoConfiguration) Validate() error {
+	var errs []error
+	paths := map[string]int{}
+	for i, h := range c.Hooks {
+		for _, err := range h.validate() {
+			errs = append(errs, fmt.Errorf("hooks[%d]: %w", i, err))
+		}
+		if prev, ok := paths[h.Path]; ok && h.Path != "" {
+			errs = append(errs, fmt.Errorf("hooks[%d]: duplicate path %q, also used by hooks[%d]", i, h.Path, prev))
+		}
+		paths[h.Path] = i
+	}
+
+	targets := map[string]*Repository{}
+	for i, r := range c.Repositories {
+		if r.source == "" {
+			r.index = i
+		}
+		for _, err := range r.validate() {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", r.location(), r.displayName(), err))
+		}
+		for _, t := range r.AllTargets() {
+			key := strings.Join([]string{r.Name, r.NameMatch, r.NameGlob, r.SourceRepo, r.SourceBranch, t.FilePath, t.UpdateKey}, "\x00")
+			if prev, ok := targets[key]; ok && prev != r {
+				errs = append(errs, fmt.Errorf("%s (%s): duplicate of %s, both update %s in %s", r.location(), r.displayName(), prev.location(), t.UpdateKey, t.FilePath))
+				continue
+			}
+			targets[key] = r
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *Hook) validate() []error {
+	var errs []error
+	if h.Path == "" {
+		errs = append(errs, errors.New("path is required"))
+	} else if !strings.HasPrefix(h.Path, "/") {
+		errs = append(errs, fmt.Errorf("invalid path %q, must start with /", h.Path))
+	}
+	if h.Parser == "" {
+		errs = append(errs, errors.New("parser is required"))
+	}
+	return errs
+}
+
+func (r *Repository) validate() []error {
+	var errs []error
+	switch names := countSet(r.Name, r.NameMatch, r.NameGlob); {
+	case names == 0:
+		errs = append(errs, errors.New("one of name, nameMatch or nameGlob is required"))
+	case names > 1:
+		errs = append(errs, errors.New("only one of name, nameMatch or nameGlob can be set"))
+	default:
+		re, err := r.matcher()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		r.nameRE = re
+	}
+	if r.SourceRepo == "" {
+		errs = append(errs, errors.New("sourceRepo is required"))
+	}
+	if r.SourceBranch == "" {
+		errs = append(errs, errors.New("sourceBranch is required"))
+	}
+
+	if r.nameRE != nil {
+		errs = append(errs, r.validateCaptures()...)
+	}
+
+	if len(r.AllTargets()) == 0 {
+		errs = append(errs, errors.New("filePath and updateKey, or targets, are required"))
+	}
+	if r.FilePath != "" || r.UpdateKey != "" {
+		errs = append(errs, r.validateTarget("", Target{FilePath: r.FilePath, UpdateKey: r.UpdateKey})...)
+	}
+	for i, t := range r.Targets {
+		errs = append(errs, r.validateTarget(fmt.Sprintf("targets[%d].", i), t)...)
+	}
+
+	if r.TagMatch != "" {
+		re, err := regexp.Compile(r.TagMatch)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to compile TagMatch regular expression: %w", err))
+		}
+		r.tagMatchRE = re
+	}
+	if r.TagPolicy != nil && r.TagPolicy.Semver != "" {
+		c, err := semver.NewConstraint(r.TagPolicy.Semver)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid tagPolicy.semver constraint %q: %w", r.TagPolicy.Semver, err))
+		}
+		r.semverConstraints = c
+	}
+	switch r.PinDigest {
+	case "", PinDigestWithTag, PinDigestOnly:
+	default:
+		errs = append(errs, fmt.Errorf("invalid pinDigest %q, must be %q or %q", r.PinDigest, PinDigestWithTag, PinDigestOnly))
+	}
+	return errs
+}
+
+func (r *Repository) validateTarget(prefix string, t Target) []error {
+	var errs []error
+	if t.FilePath == "" {
+		errs = append(errs, fmt.Errorf("%sfilePath is required", prefix))
+	}
+	if t.UpdateKey == "" {
+		errs = append(errs, fmt.Errorf("%supdateKey is required", prefix))
+	}
+	return errs
+}
+
+// validateCaptures checks that the fields that the capture groups are
+// substituted into only refer to groups in the pattern, references to unknown
+// groups are replaced with "" when the repository matches.
+func (r *Repository) validateCaptures() []error {
+	fields := []struct {
+		name, value string
+	}{
+		{"filePath", r.FilePath},
+		{"updateKey", r.UpdateKey},
+		{"branchGenerateName", r.BranchGenerateName},
+	}
+	for i, t := range r.Targets {
+		fields = append(fields,
+			struct{ name, value string }{fmt.Sprintf("targets[%d].filePath", i), t.FilePath},
+			struct{ name, value string }{fmt.Sprintf("targets[%d].updateKey", i), t.UpdateKey})
+	}
+
+	var errs []error
+	for _, f := range fields {
+		for _, name := range captureReferences(f.value) {
+			if knownGroup(r.nameRE, name) {
+				continue
+			}
+			err := fmt.Errorf("%s refers to unknown capture group %q", f.name, name)
+			for n := len(name) - 1; n > 0; n-- {
+				if knownGroup(r.nameRE, name[:n]) {
+					err = fmt.Errorf("%w, use ${%s}%s", err, name[:n], name[n:])
+					break
+				}
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// location identifies the entry for the repository in errors, repositories
+// from included files are identified by the file, and their index in it.
+func (r *Repository) location() string {
+	if r.source == "" {
+		return fmt.Sprintf("repositories[%d]", r.index)
+	}
+	return fmt.Sprintf("%s: repositories[%d]", r.source, r.index)
+}
+
+// displayName returns the name or pattern that identifies the repository in
+// errors.
+func (r *Repository) displayName() string {
+	for _, s := range []string{r.Name, r.NameMatch, r.NameGlob} {
+		if s != "" {
+			return s
+		}
+	}
+	return "unnamed"
+}
+
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
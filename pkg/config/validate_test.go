@@ -0,0 +1,216 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gitops-tools/image-updater/test"
+)
+
+func TestValidate(t *testing.T) {
+	validTests := []struct {
+		name    string
+		cfg     *RepoConfiguration
+		wantErr string
+	}{
+		{"valid", &RepoConfiguration{Repositories: []*Repository{validRepository()}}, ""},
+		{
+			"valid targets",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) {
+				r.FilePath = ""
+				r.UpdateKey = ""
+				r.Targets = []Target{{FilePath: "test/file.yaml", UpdateKey: "spec.image"}}
+			})}},
+			"",
+		},
+		{
+			"missing fields",
+			&RepoConfiguration{Repositories: []*Repository{validRepository(), {Name: "testing/other-image"}}},
+			`^repositories\[1\] \(testing/other-image\): sourceRepo is required
+repositories\[1\] \(testing/other-image\): sourceBranch is required
+repositories\[1\] \(testing/other-image\): filePath and updateKey, or targets, are required$`,
+		},
+		{
+			"missing name",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) { r.Name = "" })}},
+			`repositories\[0\] \(unnamed\): one of name, nameMatch or nameGlob is required`,
+		},
+		{
+			"multiple names",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) { r.NameGlob = "testing/*" })}},
+			`repositories\[0\] \(testing/repo-image\): only one of name, nameMatch or nameGlob can be set`,
+		},
+		{
+			"invalid nameMatch",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) { r.Name = ""; r.NameMatch = "testing/(" })}},
+			`repositories\[0\] \(testing/\(\): failed to compile nameMatch`,
+		},
+		{
+			"capture group references",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) {
+				r.Name = ""
+				r.NameMatch = "testing/(?P<app>[^/]*)/(.*)"
+				r.FilePath = "$app/${2}/$$1x/${0}.yaml"
+				r.BranchGenerateName = "update-${app}-$1-"
+			})}},
+			"",
+		},
+		{
+			"unknown capture groups",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) {
+				r.Name = ""
+				r.NameMatch = "testing/(?P<app>.*)"
+				r.FilePath = "test/$1x/file.yaml"
+				r.BranchGenerateName = "update-${name}-$app_"
+				r.Targets = []Target{{FilePath: "test/$2.yaml", UpdateKey: "spec.image"}}
+			})}},
+			`^repositories\[0\] \(testing/\(\?P<app>\.\*\)\): filePath refers to unknown capture group "1x", use \$\{1\}x
+repositories\[0\] \(testing/\(\?P<app>\.\*\)\): branchGenerateName refers to unknown capture group "name"
+repositories\[0\] \(testing/\(\?P<app>\.\*\)\): branchGenerateName refers to unknown capture group "app_", use \$\{app\}_
+repositories\[0\] \(testing/\(\?P<app>\.\*\)\): targets\[0\].filePath refers to unknown capture group "2"$`,
+		},
+		{
+			"unknown glob capture group",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) {
+				r.Name = ""
+				r.NameGlob = "testing/*"
+				r.UpdateKey = "spec.$2.image"
+			})}},
+			`^repositories\[0\] \(testing/\*\): updateKey refers to unknown capture group "2"$`,
+		},
+		{
+			"missing update key",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) { r.UpdateKey = "" })}},
+			`^repositories\[0\] \(testing/repo-image\): updateKey is required$`,
+		},
+		{
+			"missing target file path",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) {
+				r.Targets = []Target{{UpdateKey: "spec.image"}}
+			})}},
+			`^repositories\[0\] \(testing/repo-image\): targets\[0\].filePath is required$`,
+		},
+		{
+			"invalid tagMatch",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) { r.TagMatch = "[" })}},
+			`repositories\[0\] \(testing/repo-image\): failed to compile TagMatch regular expression`,
+		},
+		{
+			"invalid semver constraint",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) { r.TagPolicy = &TagPolicy{Semver: ">=1.2 <<2"} })}},
+			`repositories\[0\] \(testing/repo-image\): invalid tagPolicy.semver constraint ">=1.2 <<2"`,
+		},
+		{
+			"invalid pinDigest",
+			&RepoConfiguration{Repositories: []*Repository{withRepository(func(r *Repository) { r.PinDigest = "always" })}},
+			`repositories\[0\] \(testing/repo-image\): invalid pinDigest "always"`,
+		},
+		{
+			"duplicate repository",
+			&RepoConfiguration{Repositories: []*Repository{validRepository(), validRepository()}},
+			`^repositories\[1\] \(testing/repo-image\): duplicate of repositories\[0\], both update person.name in test/file.yaml$`,
+		},
+		{
+			"same name in different files",
+			&RepoConfiguration{Repositories: []*Repository{validRepository(), withRepository(func(r *Repository) { r.FilePath = "test/other.yaml" })}},
+			"",
+		},
+		{
+			"invalid hooks",
+			&RepoConfiguration{
+				Hooks: []*Hook{
+					{Path: "/quay", Parser: "quay"},
+					{Path: "quay"},
+					{Path: "/quay", Parser: "docker"},
+				},
+			},
+			`^hooks\[1\]: invalid path "quay", must start with /
+hooks\[1\]: parser is required
+hooks\[2\]: duplicate path "/quay", also used by hooks\[0\]$`,
+		},
+	}
+
+	for _, tt := range validTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCompilesRegularExpressions(t *testing.T) {
+	r := withRepository(func(r *Repository) {
+		r.Name = ""
+		r.NameMatch = "testing/(.*)"
+		r.TagMatch = "^v"
+		r.TagPolicy = &TagPolicy{Semver: ">=1.2"}
+	})
+	cfg := &RepoConfiguration{Repositories: []*Repository{r}}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.nameRE == nil || r.tagMatchRE == nil || r.semverConstraints == nil {
+		t.Fatalf("regular expressions not compiled, got %v, %v and %v", r.nameRE, r.tagMatchRE, r.semverConstraints)
+	}
+	re, err := r.TagMatchRegexp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re != r.tagMatchRE {
+		t.Fatal("TagMatchRegexp() didn't return the compiled regular expression")
+	}
+	c, err := r.SemverConstraints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != r.semverConstraints {
+		t.Fatal("SemverConstraints() didn't return the compiled constraint")
+	}
+}
+
+func TestParseWithUnknownFields(t *testing.T) {
+	_, err := Parse(strings.NewReader(`repositories:
+  - name: testing/repo-image
+    sourceRepo: example/example-source
+    sourceBranch: main
+    fileName: test/file.yaml
+    updateKey: person.name
+`))
+
+	if !test.MatchError(t, `failed to unmarshal YAML: .*unknown field "fileName"`, err) {
+		t.Fatalf("got error %v", err)
+	}
+}
+
+func TestParseWithInvalidConfig(t *testing.T) {
+	_, err := Parse(strings.NewReader(`repositories:
+  - name: testing/repo-image
+    sourceBranch: main
+    filePath: test/file.yaml
+    updateKey: person.name
+`))
+
+	if !test.MatchError(t, `invalid configuration: repositories\[0\] \(testing/repo-image\): sourceRepo is required`, err) {
+		t.Fatalf("got error %v", err)
+	}
+}
+
+func validRepository() *Repository {
+	return &Repository{
+		Name:         "testing/repo-image",
+		SourceRepo:   "example/example-source",
+		SourceBranch: "main",
+		FilePath:     "test/file.yaml",
+		UpdateKey:    "person.name",
+	}
+}
+
+func withRepository(f func(*Repository)) *Repository {
+	r := validRepository()
+	f(r)
+	return r
+}
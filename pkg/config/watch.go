@@ -0,0 +1,159 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+)
+
+// Watch watches the configuration file at path, and the files and directories
+// that it includes, and calls f with the new configuration each time they
+// change, until the context is cancelled.
+//
+// If the new configuration is invalid, the error is logged and f is not
+// called, so that the old configuration continues to be used.
+//
+// Kubernetes updates ConfigMap volumes by swapping a symlink to a directory
+// with the new files, so the directory that contains the file is watched, and
+// the configuration is reloaded when the file is written, or the file that
+// the path resolves to changes.
+//
+// The included files are resolved again each time the configuration is
+// reloaded, so that newly included files are also watched.
+func Watch(ctx context.Context, l logr.Logger, path string, f func(*RepoConfiguration), opts ...Option) error {
+	o := makeOptions(opts)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	realPath, _ := filepath.EvalSymlinks(path)
+	inc := &includes{watcher: watcher, log: l, mainDir: filepath.Dir(path)}
+	if cfg, err := reload(path, o); err == nil {
+		inc.watch(cfg.included)
+	}
+	if o.watching != nil {
+		o.watching()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			l.Error(err, "config watcher failed", "path", path)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			currentPath, _ := filepath.EvalSymlinks(path)
+			written := filepath.Clean(event.Name) == path && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+			swapped := currentPath != "" && currentPath != realPath
+			if !written && !swapped && !inc.changed(event) {
+				continue
+			}
+			realPath = currentPath
+			cfg, err := reload(path, o)
+			if err != nil {
+				l.Error(err, "failed to reload config, keeping the current config", "path", path)
+				continue
+			}
+			inc.watch(cfg.included)
+			l.Info("reloaded config", "path", path, "repositories", len(cfg.Repositories))
+			f(cfg)
+		}
+	}
+}
+
+// includes watches the files and directories included by the configuration.
+//
+// The directory of each included path is watched, and included directories
+// are also watched, so that files added to them are detected.
+type includes struct {
+	watcher *fsnotify.Watcher
+	log     logr.Logger
+	mainDir string
+
+	paths     []string
+	realPaths map[string]string
+	dirs      map[string]bool
+}
+
+// watch replaces the watched paths with the included paths.
+func (i *includes) watch(paths []string) {
+	dirs := map[string]bool{}
+	realPaths := map[string]string{}
+	for _, p := range paths {
+		dirs[filepath.Dir(p)] = true
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			dirs[p] = true
+		}
+		realPaths[p], _ = filepath.EvalSymlinks(p)
+	}
+	delete(dirs, i.mainDir)
+
+	for dir := range i.dirs {
+		if !dirs[dir] {
+			if err := i.watcher.Remove(dir); err != nil {
+				i.log.Error(err, "failed to stop watching included directory", "path", dir)
+			}
+		}
+	}
+	for dir := range dirs {
+		if i.dirs[dir] {
+			continue
+		}
+		if err := i.watcher.Add(dir); err != nil {
+			i.log.Error(err, "failed to watch included directory", "path", dir)
+			delete(dirs, dir)
+		}
+	}
+	i.paths, i.realPaths, i.dirs = paths, realPaths, dirs
+}
+
+// changed returns true if the event is a change to an included file, or to a
+// file in an included directory, or an included path now resolves to a
+// different file.
+func (i *includes) changed(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Clean(event.Name)
+	for _, p := range i.paths {
+		if name == p || filepath.Dir(name) == p {
+			return true
+		}
+		if current, _ := filepath.EvalSymlinks(p); current != i.realPaths[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// reload loads the configuration, files that are being written are
+// truncated first, so empty files are rejected, rather than removing all the
+// repositories.
+func reload(path string, o *options) (*RepoConfiguration, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, errors.New("config file is empty")
+	}
+	return parseConfig(body, path, o)
+}
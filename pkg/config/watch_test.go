@@ -0,0 +1,174 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+const (
+	testConfig = `repositories:
+  - name: testing/repo-image
+    sourceRepo: example/example-source
+    sourceBranch: main
+    filePath: test/file.yaml
+    updateKey: person.name
+`
+	updatedConfig = `repositories:
+  - name: testing/updated-image
+    sourceRepo: example/example-source
+    sourceBranch: main
+    filePath: test/file.yaml
+    updateKey: person.name
+`
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, testConfig)
+	reloaded := watch(t, path)
+
+	writeFile(t, path, updatedConfig)
+
+	assertReloaded(t, reloaded, "testing/updated-image")
+}
+
+func TestWatchWithInvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, testConfig)
+	reloaded := watch(t, path)
+
+	writeFile(t, path, "repositories: [")
+	select {
+	case cfg := <-reloaded:
+		t.Fatalf("invalid config was reloaded: %#v", cfg)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	writeFile(t, path, updatedConfig)
+	assertReloaded(t, reloaded, "testing/updated-image")
+}
+
+// Kubernetes mounts ConfigMaps as a symlink to a ..data symlink, which points
+// to a timestamped directory, and updates them by replacing the ..data
+// symlink.
+func TestWatchWithConfigMapUpdate(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "..2026_01", "config.yaml"), testConfig)
+	symlink(t, "..2026_01", filepath.Join(dir, "..data"))
+	symlink(t, filepath.Join("..data", "config.yaml"), filepath.Join(dir, "config.yaml"))
+	reloaded := watch(t, filepath.Join(dir, "config.yaml"))
+
+	writeFile(t, filepath.Join(dir, "..2026_02", "config.yaml"), updatedConfig)
+	symlink(t, "..2026_02", filepath.Join(dir, "..data_tmp"))
+	if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "..2026_01")); err != nil {
+		t.Fatal(err)
+	}
+
+	assertReloaded(t, reloaded, "testing/updated-image")
+}
+
+func TestWatchWithIncludedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, "include:\n  - teams\n")
+	writeFile(t, filepath.Join(dir, "teams", "team-a.yaml"), testConfig)
+	reloaded := watch(t, path)
+
+	writeFile(t, filepath.Join(dir, "teams", "team-a.yaml"), updatedConfig)
+
+	assertReloaded(t, reloaded, "testing/updated-image")
+}
+
+func TestWatchWithNewlyIncludedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, testConfig)
+	writeFile(t, filepath.Join(dir, "teams", "team-a.yaml"), "repositories: []\n")
+	reloaded := watch(t, path)
+
+	writeFile(t, path, "include:\n  - teams/team-a.yaml\n")
+	assertReloaded(t, reloaded, "")
+	writeFile(t, filepath.Join(dir, "teams", "team-a.yaml"), updatedConfig)
+
+	assertReloaded(t, reloaded, "testing/updated-image")
+}
+
+func watch(t *testing.T, path string) chan *RepoConfiguration {
+	t.Helper()
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	ctx, cancel := context.WithCancel(context.Background())
+	reloaded := make(chan *RepoConfiguration, 10)
+	errc := make(chan error, 1)
+	watching := make(chan struct{})
+	go func() {
+		errc <- Watch(ctx, logger, path, func(cfg *RepoConfiguration) {
+			reloaded <- cfg
+		}, func(o *options) {
+			o.watching = func() { close(watching) }
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-errc; err != nil {
+			t.Error(err)
+		}
+	})
+	select {
+	case <-watching:
+	case err := <-errc:
+		t.Fatalf("watch failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the watcher to start")
+	}
+	return reloaded
+}
+
+// assertReloaded waits for a configuration with a single repository with the
+// name, or no repositories if the name is empty.
+func assertReloaded(t *testing.T, reloaded chan *RepoConfiguration, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case cfg := <-reloaded:
+			// A single update can generate several events.
+			if want == "" && len(cfg.Repositories) == 0 {
+				return
+			}
+			if len(cfg.Repositories) == 1 && cfg.Repositories[0].Name == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for config with %s", want)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, oldname, newname string) {
+	t.Helper()
+	if err := os.Symlink(oldname, newname); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,50 +1,225 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 
 	"github.com/gitops-tools/image-updater/pkg/applier"
+	"github.com/gitops-tools/image-updater/pkg/auth"
 	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/queue"
+	"github.com/gitops-tools/image-updater/pkg/retry"
 )
 
+const (
+	// DefaultMaxBodySize is the default limit on the size of hook request
+	// bodies.
+	DefaultMaxBodySize = 1024 * 1024
+
+	// DefaultRetryAfter is the default delay that clients are asked to wait
+	// before retrying when the queue is full, or updates fail with transient
+	// errors.
+	DefaultRetryAfter = 30 * time.Second
+)
+
+// Option is an option for creating new Handlers.
+type Option func(h *Handler)
+
+// Authentication is an option that configures the Handler to authenticate
+// requests before they are parsed.
+func Authentication(a auth.Authenticator) Option {
+	return func(h *Handler) {
+		h.authenticator = a
+	}
+}
+
+// MaxBodySize is an option that limits the size of the request bodies that
+// the Handler will read, larger requests are rejected.
+func MaxBodySize(n int64) Option {
+	return func(h *Handler) {
+		h.maxBodySize = n
+	}
+}
+
+// RetryAfter is an option that configures the minimum delay that clients are
+// asked to wait before retrying when updates fail with transient errors.
+func RetryAfter(d time.Duration) Option {
+	return func(h *Handler) {
+		h.retryAfter = d
+	}
+}
+
+// Queue is an option that configures the Handler to queue the parsed events
+// to be processed asynchronously, and respond with 202 Accepted.
+//
+// When the queue is full, requests are rejected with 503 Service Unavailable
+// and a Retry-After header with the delay.
+func Queue(q *queue.Queue, retryAfter time.Duration) Option {
+	return func(h *Handler) {
+		h.work = q
+		h.retryAfter = retryAfter
+	}
+}
+
 // Handler parses and processes hook notifications.
 type Handler struct {
-	log     logr.Logger
-	applier *applier.Applier
-	parser  hooks.PushEventParser
+	log           logr.Logger
+	applier       *applier.Applier
+	parser        hooks.PushEventParser
+	authenticator auth.Authenticator
+	maxBodySize   int64
+	work          *queue.Queue
+	retryAfter    time.Duration
+}
+
+// acceptedResponse is the body of the response when events are queued.
+type acceptedResponse struct {
+	EventID string `json:"eventID"`
+}
+
+// requestError is returned when processing fails because of the request, and
+// carries the status code to respond with.
+type requestError struct {
+	status int
+	err    error
+}
+
+func (e requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e requestError) Unwrap() error {
+	return e.err
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	hook, err := h.parse(r)
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !isJSON(r.Header.Get("Content-Type")) {
+		h.log.Info("rejected request with unsupported content type", "contentType", r.Header.Get("Content-Type"))
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	events, err := h.parse(r)
+	if errors.Is(err, auth.ErrUnauthorized) {
+		h.log.Info("rejected unauthenticated request", "remoteAddr", r.RemoteAddr, "reason", err.Error())
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
 		h.log.Error(err, "failed to parse request")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		var re requestError
+		if errors.As(err, &re) {
+			status = re.status
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if len(events) == 0 {
+		h.log.Info("ignoring hook request")
+		return
+	}
+	if h.work != nil {
+		h.enqueue(w, events)
+		return
+	}
+
+	var errs []error
+	transient, retryAfter := false, h.retryAfter
+	for _, hook := range events {
+		if err := h.applier.UpdateFromHook(r.Context(), hook); err != nil {
+			h.log.Error(err, "hook update failed", "repository", hook.EventRepository(), "tag", hook.EventTag(), "transient", retry.IsTransient(err))
+			errs = append(errs, err)
+			if retry.IsTransient(err) {
+				transient = true
+				if d := retry.RetryAfter(err); d > retryAfter {
+					retryAfter = d
+				}
+			}
+		}
+	}
+	err = errors.Join(errs...)
+	if err == nil {
 		return
 	}
-	err = h.applier.UpdateFromHook(r.Context(), hook)
+	// If any of the updates failed with a transient error, the registry
+	// should redeliver the hook later.
+	if transient {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
 
+func (h *Handler) enqueue(w http.ResponseWriter, events []hooks.PushEvent) {
+	id, err := h.work.Enqueue(events)
 	if err != nil {
-		h.log.Error(err, "hook update failed")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		h.log.Error(err, "failed to queue hook events")
+		w.Header().Set("Retry-After", strconv.Itoa(int(h.retryAfter.Seconds())))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(acceptedResponse{EventID: id}); err != nil {
+		h.log.Error(err, "failed to write response", "eventID", id)
+	}
 }
 
-func (h *Handler) parse(r *http.Request) (hooks.PushEvent, error) {
+func (h *Handler) parse(r *http.Request) ([]hooks.PushEvent, error) {
 	h.log.Info("processing hook request")
-	// TODO: LimitReader
-	data, err := ioutil.ReadAll(r.Body)
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, h.maxBodySize+1))
 	if err != nil {
 		h.log.Error(err, "failed to read request body")
 		return nil, err
 	}
-	return h.parser(data)
+	if int64(len(data)) > h.maxBodySize {
+		return nil, requestError{status: http.StatusRequestEntityTooLarge, err: fmt.Errorf("request body exceeds %d bytes", h.maxBodySize)}
+	}
+	if h.authenticator != nil {
+		if err := h.authenticator.Authenticate(r, data); err != nil {
+			return nil, err
+		}
+	}
+	events, err := h.parser(data)
+	if err != nil {
+		return nil, requestError{status: http.StatusBadRequest, err: err}
+	}
+	return events, nil
+}
+
+// isJSON returns true for the application/json media type, and media types
+// with the +json suffix e.g. the type used by Docker Distribution for
+// notifications.
+func isJSON(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
 }
 
 // New creates and returns a new Handler.
-func New(logger logr.Logger, u *applier.Applier, p hooks.PushEventParser) *Handler {
-	return &Handler{log: logger, applier: u, parser: p}
+func New(logger logr.Logger, u *applier.Applier, p hooks.PushEventParser, opts ...Option) *Handler {
+	h := &Handler{log: logger, applier: u, parser: p, maxBodySize: DefaultMaxBodySize, retryAfter: DefaultRetryAfter}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
 }
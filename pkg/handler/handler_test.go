@@ -2,12 +2,15 @@ package handler
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gitops-tools/pkg/client/mock"
 	"github.com/gitops-tools/pkg/updater"
@@ -17,9 +20,13 @@ import (
 	"go.uber.org/zap/zaptest"
 
 	"github.com/gitops-tools/image-updater/pkg/applier"
+	"github.com/gitops-tools/image-updater/pkg/auth"
 	"github.com/gitops-tools/image-updater/pkg/config"
 	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/hooks/distribution"
 	"github.com/gitops-tools/image-updater/pkg/hooks/quay"
+	"github.com/gitops-tools/image-updater/pkg/queue"
+	"github.com/gitops-tools/image-updater/pkg/retry"
 )
 
 const (
@@ -48,8 +55,156 @@ func TestHandler(t *testing.T) {
 	})
 }
 
+func TestHandlerWithAuthentication(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+	h := New(logger, applier, quay.Parse, Authentication(auth.Config{HMACSecret: "testing"}))
+	rec := httptest.NewRecorder()
+	req := makeHookRequest(t, "testdata/push_hook.json")
+	req.Header.Set(auth.DefaultSignatureHeader, auth.Sign("testing", readBody(t, req)))
+
+	h.ServeHTTP(rec, req)
+
+	if res := rec.Result(); res.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode got %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	m.AssertPullRequestCreated(testGitHubRepo, &scm.PullRequestInput{
+		Body:  fmt.Sprintf("Automated update from %q", testQuayRepo),
+		Head:  "test-branch-a",
+		Base:  "master",
+		Title: "Automated image update",
+	})
+}
+
+func TestHandlerWithAuthenticationFailure(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+	h := New(logger, applier, quay.Parse, Authentication(auth.Config{HMACSecret: "testing"}))
+	rec := httptest.NewRecorder()
+	req := makeHookRequest(t, "testdata/push_hook.json")
+	req.Header.Set(auth.DefaultSignatureHeader, auth.Sign("not-the-secret", readBody(t, req)))
+
+	h.ServeHTTP(rec, req)
+
+	m.AssertNoInteractions()
+	if res := rec.Result(); res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("StatusCode got %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+}
+
+func TestHandlerWithMultipleTags(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	configs := createConfigs()
+	configs.Repositories[0].TagMatch = "^v.*"
+	h := New(logger, applier.New(logger, m, configs, updater.NameGenerator(stubNameGenerator{"a"})), quay.Parse)
+	rec := httptest.NewRecorder()
+	req := makeHookRequest(t, "testdata/push_hook_multiple_tags.json")
+
+	h.ServeHTTP(rec, req)
+
+	want := "test:\n  image: quay.io/mynamespace/repository:v1.2.3\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+	if res := rec.Result(); res.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode got %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestHandlerWithDistributionEnvelope(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	configs := createConfigs()
+	configs.Repositories[0].Name = "team/service-a"
+	h := New(logger, applier.New(logger, m, configs, updater.NameGenerator(stubNameGenerator{"a"})), distribution.Parse)
+	rec := httptest.NewRecorder()
+	req := makeHookRequest(t, "testdata/distribution_envelope.json")
+
+	h.ServeHTTP(rec, req)
+
+	want := "test:\n  image: registry.example.com:5000/team/service-a:v1.0.0\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+	m.AssertPullRequestCreated(testGitHubRepo, &scm.PullRequestInput{
+		Body:  fmt.Sprintf("Automated update from %q", "team/service-a"),
+		Head:  "test-branch-a",
+		Base:  "master",
+		Title: "Automated image update",
+	})
+}
+
+func TestHandlerWithQueue(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+	q := queue.New(logger, applier, 1)
+	h := New(logger, applier, quay.Parse, Queue(q, time.Minute))
+	rec := httptest.NewRecorder()
+	req := makeHookRequest(t, "testdata/push_hook.json")
+
+	h.ServeHTTP(rec, req)
+
+	res := rec.Result()
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("StatusCode got %d, want %d", res.StatusCode, http.StatusAccepted)
+	}
+	var accepted acceptedResponse
+	if err := json.NewDecoder(res.Body).Decode(&accepted); err != nil {
+		t.Fatal(err)
+	}
+	if accepted.EventID == "" {
+		t.Fatal("no eventID in response")
+	}
+	m.AssertNoPullRequestsCreated()
+
+	q.Start(context.TODO(), 1)
+	q.Stop()
+	m.AssertPullRequestCreated(testGitHubRepo, &scm.PullRequestInput{
+		Body:  fmt.Sprintf("Automated update from %q", testQuayRepo),
+		Head:  "test-branch-a",
+		Base:  "master",
+		Title: "Automated image update",
+	})
+}
+
+func TestHandlerWithFullQueue(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+	h := New(logger, applier, quay.Parse, Queue(queue.New(logger, applier, 0), time.Minute))
+	rec := httptest.NewRecorder()
+	req := makeHookRequest(t, "testdata/push_hook.json")
+
+	h.ServeHTTP(rec, req)
+
+	m.AssertNoInteractions()
+	res := rec.Result()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("StatusCode got %d, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+	if v := res.Header.Get("Retry-After"); v != "60" {
+		t.Fatalf("Retry-After got %q, want %q", v, "60")
+	}
+}
+
 func TestHandlerWithParseFailure(t *testing.T) {
-	badParser := func(payload []byte) (hooks.PushEvent, error) {
+	badParser := func(payload []byte) ([]hooks.PushEvent, error) {
 		return nil, errors.New("failed")
 	}
 	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
@@ -63,8 +218,90 @@ func TestHandlerWithParseFailure(t *testing.T) {
 
 	m.AssertNoPullRequestsCreated()
 	res := rec.Result()
-	if res.StatusCode != http.StatusInternalServerError {
-		t.Fatalf("StatusCode got %d, want %d", res.StatusCode, http.StatusInternalServerError)
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("StatusCode got %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerRejectsInvalidRequests(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+	h := New(logger, applier, quay.Parse, MaxBodySize(100))
+
+	requestTests := []struct {
+		name       string
+		req        func(*http.Request)
+		wantStatus int
+	}{
+		{"GET request", func(r *http.Request) { r.Method = http.MethodGet }, http.StatusMethodNotAllowed},
+		{"no content type", func(r *http.Request) { r.Header.Del("Content-Type") }, http.StatusUnsupportedMediaType},
+		{"form content type", func(r *http.Request) { r.Header.Set("Content-Type", "application/x-www-form-urlencoded") }, http.StatusUnsupportedMediaType},
+		{"body too large", func(r *http.Request) {}, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range requestTests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := makeHookRequest(t, "testdata/push_hook.json")
+			tt.req(req)
+
+			h.ServeHTTP(rec, req)
+
+			if res := rec.Result(); res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode got %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			m.AssertNoInteractions()
+		})
+	}
+}
+
+func TestHandlerAcceptsJSONContentTypes(t *testing.T) {
+	contentTypes := []string{
+		"application/json",
+		"application/json; charset=utf-8",
+		"application/vnd.docker.distribution.events.v1+json",
+	}
+
+	for _, ct := range contentTypes {
+		t.Run(ct, func(t *testing.T) {
+			logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+			m := mock.New(t)
+			applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+			ignoringParser := func(payload []byte) ([]hooks.PushEvent, error) {
+				return nil, nil
+			}
+			h := New(logger, applier, ignoringParser)
+			rec := httptest.NewRecorder()
+			req := makeHookRequest(t, "testdata/push_hook.json")
+			req.Header.Set("Content-Type", ct)
+
+			h.ServeHTTP(rec, req)
+
+			if res := rec.Result(); res.StatusCode != http.StatusOK {
+				t.Fatalf("StatusCode got %d, want %d", res.StatusCode, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestHandlerWithIgnoredEvent(t *testing.T) {
+	ignoringParser := func(payload []byte) ([]hooks.PushEvent, error) {
+		return nil, nil
+	}
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+	h := New(logger, applier, ignoringParser)
+	rec := httptest.NewRecorder()
+	req := makeHookRequest(t, "testdata/push_hook.json")
+
+	h.ServeHTTP(rec, req)
+
+	m.AssertNoInteractions()
+	res := rec.Result()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode got %d, want %d", res.StatusCode, http.StatusOK)
 	}
 }
 
@@ -85,6 +322,49 @@ func TestHandlerWithFailureToUpdate(t *testing.T) {
 	}
 }
 
+func TestHandlerWithTransientFailureToUpdate(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	m.GetFileErr = errors.New(http.StatusText(http.StatusServiceUnavailable))
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+	applier.SetBackoff(retry.Backoff{Attempts: 2, Initial: time.Millisecond, Max: time.Second})
+	h := New(logger, applier, quay.Parse)
+	rec := httptest.NewRecorder()
+	req := makeHookRequest(t, "testdata/push_hook.json")
+
+	h.ServeHTTP(rec, req)
+
+	m.AssertNoPullRequestsCreated()
+	res := rec.Result()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("StatusCode got %d, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+	if v := res.Header.Get("Retry-After"); v != "30" {
+		t.Fatalf("Retry-After got %q, want %q", v, "30")
+	}
+}
+
+func TestHandlerWithRetryAfter(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	m.GetFileErr = errors.New(http.StatusText(http.StatusServiceUnavailable))
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+	applier.SetBackoff(retry.Backoff{Attempts: 1, Initial: time.Millisecond, Max: time.Second})
+	h := New(logger, applier, quay.Parse, RetryAfter(2*time.Minute))
+	rec := httptest.NewRecorder()
+	req := makeHookRequest(t, "testdata/push_hook.json")
+
+	h.ServeHTTP(rec, req)
+
+	res := rec.Result()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("StatusCode got %d, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+	if v := res.Header.Get("Retry-After"); v != "120" {
+		t.Fatalf("Retry-After got %q, want %q", v, "120")
+	}
+}
+
 func TestParseWithNoBody(t *testing.T) {
 	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
 	m := mock.New(t)
@@ -126,6 +406,18 @@ func makeHookRequest(t *testing.T, fixture string) *http.Request {
 	return req
 }
 
+// readBody reads the body from the request, and replaces it so that it can be
+// read again.
+func readBody(t *testing.T, r *http.Request) []byte {
+	t.Helper()
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b
+}
+
 func createConfigs() *config.RepoConfiguration {
 	return &config.RepoConfiguration{
 		Repositories: []*config.Repository{
@@ -0,0 +1,81 @@
+package auto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/hooks/distribution"
+	"github.com/gitops-tools/image-updater/pkg/hooks/docker"
+	"github.com/gitops-tools/image-updater/pkg/hooks/gcr"
+	"github.com/gitops-tools/image-updater/pkg/hooks/ghcr"
+	"github.com/gitops-tools/image-updater/pkg/hooks/harbor"
+	"github.com/gitops-tools/image-updater/pkg/hooks/quay"
+)
+
+const parserName = "auto"
+
+// ErrUnknownFormat is wrapped by the ParseError returned when a payload
+// doesn't match any of the known formats.
+var ErrUnknownFormat = errors.New("unknown payload format")
+
+// format identifies a payload by the top-level fields it has.
+//
+// A payload matches if it has any of the fields, and each of the required
+// fields.
+type format struct {
+	name     string
+	fields   []string
+	required []string
+	parse    hooks.PushEventParser
+}
+
+// The order matters, the GCR payload only has very generic fields, and so is
+// only matched if no other format is.
+var formats = []format{
+	{name: "distribution", fields: []string{"events"}, parse: distribution.Parse},
+	{name: "docker", fields: []string{"push_data"}, parse: docker.Parse},
+	{name: "quay", fields: []string{"docker_url", "updated_tags"}, parse: quay.Parse},
+	{name: "harbor", fields: []string{"event_data"}, required: []string{"type"}, parse: harbor.Parse},
+	{name: "ghcr", fields: []string{"package", "registry_package"}, parse: ghcr.Parse},
+	{name: "gcr", fields: []string{"tag", "digest"}, required: []string{"action"}, parse: gcr.Parse},
+}
+
+// Parse detects the format of the payload from the fields it has, and parses
+// it with the parser for that format.
+func Parse(payload []byte) ([]hooks.PushEvent, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, hooks.NewParseError(parserName, err)
+	}
+	for _, f := range formats {
+		if f.matches(fields) {
+			return f.parse(payload)
+		}
+	}
+	return nil, hooks.NewParseError(parserName, fmt.Errorf("%w, tried %s", ErrUnknownFormat, tried()))
+}
+
+func (f format) matches(fields map[string]json.RawMessage) bool {
+	for _, r := range f.required {
+		if _, ok := fields[r]; !ok {
+			return false
+		}
+	}
+	for _, k := range f.fields {
+		if _, ok := fields[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func tried() string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = fmt.Sprintf("%s (%s)", f.name, strings.Join(f.fields, " or "))
+	}
+	return strings.Join(names, ", ")
+}
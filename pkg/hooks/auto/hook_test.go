@@ -0,0 +1,98 @@
+package auto
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/hooks/distribution"
+	"github.com/gitops-tools/image-updater/pkg/hooks/docker"
+	"github.com/gitops-tools/image-updater/pkg/hooks/gcr"
+	"github.com/gitops-tools/image-updater/pkg/hooks/ghcr"
+	"github.com/gitops-tools/image-updater/pkg/hooks/harbor"
+	"github.com/gitops-tools/image-updater/pkg/hooks/quay"
+	"github.com/gitops-tools/image-updater/test"
+)
+
+var _ hooks.PushEventParser = Parse
+
+func TestParse(t *testing.T) {
+	parseTests := []struct {
+		fixture string
+		parser  hooks.PushEventParser
+	}{
+		{"../quay/testdata/push_hook.json", quay.Parse},
+		{"../docker/testdata/push_event.json", docker.Parse},
+		{"../gcr/testdata/push_event.json", gcr.Parse},
+		{"../ghcr/testdata/package_event.json", ghcr.Parse},
+		{"../ghcr/testdata/registry_package_event.json", ghcr.Parse},
+		{"../harbor/testdata/push_artifact.json", harbor.Parse},
+		{"../harbor/testdata/scanning_completed.json", harbor.Parse},
+		{"../distribution/testdata/push_envelope.json", distribution.Parse},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			payload := readFixture(t, tt.fixture)
+			want, err := tt.parser(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			events, err := Parse(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(want, events); diff != "" {
+				t.Fatalf("events don't match:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseWithInvalidPayloads(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		payload string
+		wantErr string
+	}{
+		{"invalid JSON", `[]`, "failed to parse auto payload: json: cannot unmarshal array"},
+		{"unknown format", `{"name":"test"}`, `unknown payload format, tried distribution \(events\), docker \(push_data\), quay \(docker_url or updated_tags\), harbor \(event_data\), ghcr \(package or registry_package\), gcr \(tag or digest\)`},
+		{"harbor without type", `{"event_data":{}}`, "unknown payload format"},
+		{"detected format", `{"push_data":{"tag":"latest"}}`, "failed to parse docker payload: missing required field: repository.repo_name"},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.payload))
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if !hooks.IsParseError(err) {
+				t.Fatalf("got error %#v, want a ParseError", err)
+			}
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	test.FuzzParser(f, Parse,
+		readFixture(f, "../quay/testdata/push_hook.json"),
+		readFixture(f, "../docker/testdata/push_event.json"),
+		readFixture(f, "../gcr/testdata/push_event.json"),
+		readFixture(f, "../ghcr/testdata/package_event.json"),
+		readFixture(f, "../harbor/testdata/push_artifact.json"),
+		readFixture(f, "../distribution/testdata/push_envelope.json"))
+}
+
+func readFixture(t testing.TB, fixture string) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", fixture, err)
+	}
+	return b
+}
@@ -0,0 +1,130 @@
+package distribution
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/reference"
+)
+
+const parserName = "distribution"
+
+// manifestMediaTypes are the media types of manifests, pushes of layers and
+// other blobs are also notified, but these are not images that can be used.
+var manifestMediaTypes = map[string]bool{
+	"application/vnd.docker.distribution.manifest.v1+json":      true,
+	"application/vnd.docker.distribution.manifest.v1+prettyjws": true,
+	"application/vnd.docker.distribution.manifest.v2+json":      true,
+	"application/vnd.docker.distribution.manifest.list.v2+json": true,
+	"application/vnd.oci.image.manifest.v1+json":                true,
+	"application/vnd.oci.image.index.v1+json":                   true,
+}
+
+// Parse parses a payload into a Docker Distribution notification envelope if
+// possible.
+//
+// A PushEvent is returned for each tagged manifest push in the envelope, other
+// events e.g. pulls and blob pushes are ignored.
+func Parse(payload []byte) ([]hooks.PushEvent, error) {
+	e := &Envelope{}
+	err := json.Unmarshal(payload, e)
+	if err != nil {
+		return nil, hooks.NewParseError(parserName, err)
+	}
+	if e.Events == nil {
+		return nil, hooks.MissingField(parserName, "events")
+	}
+	return e.pushes()
+}
+
+// Envelope is a struct for the Docker Distribution notification envelope.
+type Envelope struct {
+	Events []*Event `json:"events"`
+}
+
+// pushes returns the events in the envelope that are tagged manifest pushes.
+func (e Envelope) pushes() ([]hooks.PushEvent, error) {
+	var pushes []hooks.PushEvent
+	for _, ev := range e.Events {
+		if ev == nil || ev.Action != "push" || ev.Target == nil {
+			continue
+		}
+		if ev.Target.Tag == "" || !manifestMediaTypes[ev.Target.MediaType] {
+			continue
+		}
+		if err := ev.validate(); err != nil {
+			return nil, err
+		}
+		pushes = append(pushes, ev)
+	}
+	return pushes, nil
+}
+
+// Event is a single event within the Envelope.
+type Event struct {
+	ID        string   `json:"id"`
+	Timestamp string   `json:"timestamp"`
+	Action    string   `json:"action"`
+	Target    *Target  `json:"target"`
+	Request   *Request `json:"request"`
+}
+
+// PushedImageURL is an implementation of the hooks.PushEvent interface.
+//
+// The host is taken from the request that pushed the image.
+func (e Event) PushedImageURL() string {
+	if e.Request == nil || e.Request.Host == "" {
+		return fmt.Sprintf("%s:%s", e.Target.Repository, e.Target.Tag)
+	}
+	return fmt.Sprintf("%s/%s:%s", e.Request.Host, e.Target.Repository, e.Target.Tag)
+}
+
+// EventRepository is an implementation of the hooks.PushEvent interface.
+func (e Event) EventRepository() string {
+	return e.Target.Repository
+}
+
+// EventTag is an implementation of the hooks.PushEvent interface.
+func (e Event) EventTag() string {
+	return e.Target.Tag
+}
+
+// ImageDigest returns the digest of the pushed manifest.
+func (e Event) ImageDigest() (string, error) {
+	return e.Target.Digest, nil
+}
+
+func (e Event) validate() error {
+	if e.Target.Repository == "" {
+		return hooks.MissingField(parserName, "target.repository")
+	}
+	ref, err := reference.Parse(e.PushedImageURL())
+	if err != nil {
+		return hooks.NewParseError(parserName, err)
+	}
+	if ref.Tag != e.Target.Tag {
+		return hooks.NewParseError(parserName, fmt.Errorf("invalid image URL %q", e.PushedImageURL()))
+	}
+	return nil
+}
+
+// Target is part of the Event struct.
+type Target struct {
+	MediaType  string `json:"mediaType"`
+	Size       int64  `json:"size"`
+	Digest     string `json:"digest"`
+	Length     int64  `json:"length"`
+	Repository string `json:"repository"`
+	URL        string `json:"url"`
+	Tag        string `json:"tag"`
+}
+
+// Request is part of the Event struct.
+type Request struct {
+	ID        string `json:"id"`
+	Addr      string `json:"addr"`
+	Host      string `json:"host"`
+	Method    string `json:"method"`
+	UserAgent string `json:"useragent"`
+}
@@ -0,0 +1,167 @@
+package distribution
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/test"
+)
+
+var _ hooks.PushEvent = (*Event)(nil)
+var _ hooks.PushEventParser = Parse
+
+func TestParse(t *testing.T) {
+	req := readFixture(t, "testdata/push_envelope.json")
+
+	hook, err := Parse(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []hooks.PushEvent{&Event{
+		ID:        "asdf-asdf-asdf-asdf-1",
+		Timestamp: "2016-03-09T14:44:26.502973972-08:00",
+		Action:    "push",
+		Target: &Target{
+			MediaType:  "application/vnd.docker.distribution.manifest.v2+json",
+			Size:       708,
+			Digest:     "sha256:fea8895f450959fa676bcc1df0611ea93823a735a01205fd8622846041d0c7cf",
+			Length:     708,
+			Repository: "team/service-a",
+			URL:        "https://registry.example.com:5000/v2/team/service-a/manifests/sha256:fea8895f450959fa676bcc1df0611ea93823a735a01205fd8622846041d0c7cf",
+			Tag:        "v1.0.0",
+		},
+		Request: &Request{
+			ID:        "asdfasdf",
+			Addr:      "192.168.64.11:42961",
+			Host:      "registry.example.com:5000",
+			Method:    "PUT",
+			UserAgent: "docker/20.10.21 go/go1.18.7",
+		},
+	}}
+	if diff := cmp.Diff(want, hook); diff != "" {
+		t.Fatalf("hook doesn't match:\n%s", diff)
+	}
+}
+
+func TestParseWithNoManifestPushes(t *testing.T) {
+	ignoredTests := []struct {
+		name    string
+		payload string
+	}{
+		{"no events", `{"events":[]}`},
+		{"pull", `{"events":[{"action":"pull","target":{"mediaType":"application/vnd.docker.distribution.manifest.v2+json","repository":"test/repo","tag":"latest"}}]}`},
+		{"delete", `{"events":[{"action":"delete","target":{"repository":"test/repo","digest":"sha256:fea8"}}]}`},
+		{"blob push", `{"events":[{"action":"push","target":{"mediaType":"application/octet-stream","repository":"test/repo"}}]}`},
+		{"untagged manifest", `{"events":[{"action":"push","target":{"mediaType":"application/vnd.oci.image.manifest.v1+json","repository":"test/repo","digest":"sha256:fea8"}}]}`},
+	}
+
+	for _, tt := range ignoredTests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, err := Parse([]byte(tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hook) != 0 {
+				t.Fatalf("got %#v, want no events", hook)
+			}
+		})
+	}
+}
+
+func TestParseWithInvalidEvents(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		payload string
+		wantErr string
+	}{
+		{"invalid JSON", `{"events":{}}`, "failed to parse distribution payload: json: cannot unmarshal object"},
+		{"no events", `{}`, "missing required field: events"},
+		{"no repository", `{"events":[{"action":"push","target":{"mediaType":"application/vnd.oci.image.manifest.v1+json","tag":"v1"}}]}`, "missing required field: target.repository"},
+		{"invalid host", `{"events":[{"action":"push","target":{"mediaType":"application/vnd.oci.image.manifest.v1+json","repository":"test/repo","tag":"v1"},"request":{"host":"-registry.example.com"}}]}`, "invalid domain"},
+		{"invalid tag", `{"events":[{"action":"push","target":{"mediaType":"application/vnd.oci.image.manifest.v1+json","repository":"test/repo","tag":"v1:v2"}}]}`, "invalid"},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.payload))
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if !hooks.IsParseError(err) {
+				t.Fatalf("got error %#v, want a ParseError", err)
+			}
+		})
+	}
+}
+
+func TestPushedImageURL(t *testing.T) {
+	urlTests := []struct {
+		name    string
+		request *Request
+		want    string
+	}{
+		{"with host", &Request{Host: "registry.example.com:5000"}, "registry.example.com:5000/team/service-a:v1.0.0"},
+		{"without request", nil, "team/service-a:v1.0.0"},
+	}
+
+	for _, tt := range urlTests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := createHook()
+			hook.Request = tt.request
+
+			if u := hook.PushedImageURL(); u != tt.want {
+				t.Fatalf("got %s, want %s", u, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventRepository(t *testing.T) {
+	hook := createHook()
+	want := "team/service-a"
+
+	if u := hook.EventRepository(); u != want {
+		t.Fatalf("got %s, want %s", u, want)
+	}
+}
+
+func TestEventTag(t *testing.T) {
+	hook := createHook()
+	want := "v1.0.0"
+
+	if u := hook.EventTag(); u != want {
+		t.Fatalf("got %s, want %s", u, want)
+	}
+}
+
+func createHook() *Event {
+	return &Event{
+		Action: "push",
+		Target: &Target{
+			MediaType:  "application/vnd.docker.distribution.manifest.v2+json",
+			Digest:     "sha256:fea8",
+			Repository: "team/service-a",
+			Tag:        "v1.0.0",
+		},
+		Request: &Request{
+			Host: "registry.example.com:5000",
+		},
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	test.FuzzParser(f, Parse, readFixture(f, "testdata/push_envelope.json"))
+}
+
+func readFixture(t testing.TB, fixture string) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", fixture, err)
+	}
+	return b
+}
@@ -5,16 +5,22 @@ import (
 	"fmt"
 
 	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/reference"
 )
 
+const parserName = "docker"
+
 // Parse parses a payload into a Docker webhook event.
-func Parse(payload []byte) (hooks.PushEvent, error) {
+func Parse(payload []byte) ([]hooks.PushEvent, error) {
 	h := &Webhook{}
 	err := json.Unmarshal(payload, h)
 	if err != nil {
+		return nil, hooks.NewParseError(parserName, err)
+	}
+	if err := h.validate(); err != nil {
 		return nil, err
 	}
-	return h, nil
+	return []hooks.PushEvent{h}, nil
 }
 
 // Webhook is a struct for the Docker Hub webhook event.
@@ -39,6 +45,29 @@ func (p Webhook) EventTag() string {
 	return p.PushData.Tag
 }
 
+// ImageDigest is an implementation of the hooks.PushEvent interface.
+//
+// Docker Hub hooks don't include the digest of the pushed image.
+func (p Webhook) ImageDigest() (string, error) {
+	return "", nil
+}
+
+func (p Webhook) validate() error {
+	if p.Repository == nil || p.Repository.RepoName == "" {
+		return hooks.MissingField(parserName, "repository.repo_name")
+	}
+	if _, err := reference.ParseName(p.Repository.RepoName); err != nil {
+		return hooks.NewParseError(parserName, err)
+	}
+	if p.PushData == nil || p.PushData.Tag == "" {
+		return hooks.MissingField(parserName, "push_data.tag")
+	}
+	if err := reference.ValidateTag(p.PushData.Tag); err != nil {
+		return hooks.NewParseError(parserName, err)
+	}
+	return nil
+}
+
 // PushData is part of the Webhook struct.
 type PushData struct {
 	Images   []string `json:"images"`
@@ -7,6 +7,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 
 	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/test"
 )
 
 var _ hooks.PushEvent = (*Webhook)(nil)
@@ -20,7 +21,7 @@ func TestParse(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	want := &Webhook{
+	want := []hooks.PushEvent{&Webhook{
 		CallbackURL: "https://registry.hub.docker.com/u/svendowideit/testhook/hook/2141b5bi5i5b02bec211i4eeih0242eg11000a/",
 		PushData: &PushData{
 			Pusher: "trustedbuilder",
@@ -45,12 +46,38 @@ func TestParse(t *testing.T) {
 			IsTrusted:       true,
 			DateCreated:     1.417494799e+09,
 		},
-	}
+	}}
 	if diff := cmp.Diff(want, hook); diff != "" {
 		t.Fatalf("hook doesn't match:\n%s", diff)
 	}
 }
 
+func TestParseWithInvalidHooks(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		payload string
+		wantErr string
+	}{
+		{"invalid JSON", `"push_data"`, "failed to parse docker payload: json: cannot unmarshal string"},
+		{"no repository", `{"push_data":{"tag":"latest"}}`, "missing required field: repository.repo_name"},
+		{"invalid repository", `{"push_data":{"tag":"latest"},"repository":{"repo_name":"svendowideit/TestHook"}}`, "invalid repository name"},
+		{"no push data", `{"repository":{"repo_name":"svendowideit/testhook"}}`, "missing required field: push_data.tag"},
+		{"invalid tag", `{"push_data":{"tag":"latest!"},"repository":{"repo_name":"svendowideit/testhook"}}`, "invalid tag format"},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.payload))
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if !hooks.IsParseError(err) {
+				t.Fatalf("got error %#v, want a ParseError", err)
+			}
+		})
+	}
+}
+
 func TestPushedImageURL(t *testing.T) {
 	hook := &Webhook{
 		PushData: &PushData{
@@ -83,7 +110,11 @@ func TestRepository(t *testing.T) {
 	}
 }
 
-func readFixture(t *testing.T, fixture string) []byte {
+func FuzzParse(f *testing.F) {
+	test.FuzzParser(f, Parse, readFixture(f, "testdata/push_event.json"))
+}
+
+func readFixture(t testing.TB, fixture string) []byte {
 	t.Helper()
 	b, err := ioutil.ReadFile(fixture)
 	if err != nil {
@@ -0,0 +1,43 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMissingField is wrapped by ParseErrors for payloads that are missing a
+// required field.
+var ErrMissingField = errors.New("missing required field")
+
+// ParseError is returned by parsers when a payload can't be parsed into
+// PushEvents.
+type ParseError struct {
+	Parser string
+	Err    error
+}
+
+// NewParseError creates and returns a new ParseError for the named parser.
+func NewParseError(parser string, err error) error {
+	return &ParseError{Parser: parser, Err: err}
+}
+
+// MissingField returns a ParseError for the named parser indicating that the
+// field is missing from the payload.
+func MissingField(parser, field string) error {
+	return NewParseError(parser, fmt.Errorf("%w: %s", ErrMissingField, field))
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse %s payload: %s", e.Parser, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// IsParseError returns true if the error is or wraps a ParseError.
+func IsParseError(err error) bool {
+	var pe *ParseError
+	return errors.As(err, &pe)
+}
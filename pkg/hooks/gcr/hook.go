@@ -2,13 +2,18 @@ package gcr
 
 import (
 	"encoding/json"
-	"errors"
-	"strings"
+	"fmt"
 
 	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/reference"
 )
 
+const parserName = "gcr"
+
 // PushMessage is a struct for the GCR push event
+//
+// The Digest and Tag are both full image references, e.g.
+// gcr.io/my-project/my-image@sha256:... and gcr.io/my-project/my-image:latest.
 type PushMessage struct {
 	Action string `json:"action,omitempty"`
 	Digest string `json:"digest,omitempty"`
@@ -22,26 +27,64 @@ func (m PushMessage) PushedImageURL() string {
 
 // EventRepository is an implementation of the hooks.PushEvent interface.
 func (m PushMessage) EventRepository() string {
-	return strings.Split(m.Tag, ":")[0]
+	return m.reference().Name()
 }
 
 // EventTag is an implementation of the hooks.PushEvent interface.
 func (m PushMessage) EventTag() string {
-	return strings.Split(m.Tag, ":")[1]
+	return m.reference().Tag
+}
+
+// ImageDigest is an implementation of the hooks.PushEvent interface.
+//
+// The digest is parsed from the image reference in the Digest field, an error
+// is returned if it isn't a valid reference with a digest.
+func (m PushMessage) ImageDigest() (string, error) {
+	if m.Digest == "" {
+		return "", nil
+	}
+	ref, err := reference.Parse(m.Digest)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse digest %q: %w", m.Digest, err)
+	}
+	if ref.Digest == "" {
+		return "", fmt.Errorf("no digest in %q", m.Digest)
+	}
+	return ref.Digest, nil
+}
+
+func (m PushMessage) reference() *reference.Reference {
+	ref, err := reference.Parse(m.Tag)
+	if err != nil {
+		return &reference.Reference{}
+	}
+	return ref
 }
 
 // Parse parses a payload into a GCR PushEvent
-func Parse(payload []byte) (hooks.PushEvent, error) {
+//
+// Only INSERT messages for tagged images are processed, deletions and pushes
+// of untagged images (which only have a digest) are ignored.
+func Parse(payload []byte) ([]hooks.PushEvent, error) {
 	msg := &PushMessage{}
 
 	err := json.Unmarshal(payload, &msg)
 	if err != nil {
-		return nil, err
+		return nil, hooks.NewParseError(parserName, err)
 	}
-
-	if msg.Tag == "" {
-		return nil, errors.New("tag is empty")
+	if msg == nil || msg.Action == "" {
+		return nil, hooks.MissingField(parserName, "action")
+	}
+	if msg.Action != "INSERT" || msg.Tag == "" {
+		return nil, nil
+	}
+	ref, err := reference.Parse(msg.Tag)
+	if err != nil {
+		return nil, hooks.NewParseError(parserName, err)
+	}
+	if ref.Tag == "" {
+		return nil, hooks.NewParseError(parserName, fmt.Errorf("no tag in %q", msg.Tag))
 	}
 
-	return msg, nil
+	return []hooks.PushEvent{msg}, nil
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 
 	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/test"
 )
 
 var _ hooks.PushEvent = (*PushMessage)(nil)
@@ -20,16 +21,66 @@ func TestParse(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	want := &PushMessage{
-		Action: "INSERT",
-		Digest: "gcr.io/mynamespace/repository@sha256:6ec128e26cd5",
-		Tag:    "gcr.io/mynamespace/repository:latest",
+	want := []hooks.PushEvent{
+		&PushMessage{
+			Action: "INSERT",
+			Digest: "gcr.io/mynamespace/repository@sha256:6ec128e26cd5",
+			Tag:    "gcr.io/mynamespace/repository:latest",
+		},
 	}
 	if diff := cmp.Diff(want, hook); diff != "" {
 		t.Fatalf("hook doesn't match:\n%s", diff)
 	}
 }
 
+func TestParseIgnoredMessages(t *testing.T) {
+	ignoredTests := []struct {
+		name    string
+		payload string
+	}{
+		{"untagged push", `{"action":"INSERT","digest":"gcr.io/mynamespace/repository@sha256:6ec128e26cd5da4f1f2f6d2bd0e0a4c6b8f1e9f3e5a9e7d4b1c2a3f4e5d6c7b8"}`},
+		{"delete", `{"action":"DELETE","tag":"gcr.io/mynamespace/repository:latest"}`},
+	}
+
+	for _, tt := range ignoredTests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := Parse([]byte(tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 0 {
+				t.Fatalf("got %#v, want no events", events)
+			}
+		})
+	}
+}
+
+func TestParseWithInvalidMessages(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		payload string
+		wantErr string
+	}{
+		{"invalid JSON", `{"action":1}`, "failed to parse gcr payload: json: cannot unmarshal number"},
+		{"null", `null`, "missing required field: action"},
+		{"no action", `{"tag":"gcr.io/mynamespace/repository:latest"}`, "missing required field: action"},
+		{"no tag", `{"action":"INSERT","tag":"gcr.io/mynamespace/repository"}`, `no tag in "gcr.io/mynamespace/repository"`},
+		{"invalid reference", `{"action":"INSERT","tag":"gcr.io/mynamespace/Repository:latest"}`, "invalid repository name"},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.payload))
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if !hooks.IsParseError(err) {
+				t.Fatalf("got error %#v, want a ParseError", err)
+			}
+		})
+	}
+}
+
 func TestPushedImageURL(t *testing.T) {
 	hook := &PushMessage{
 		Action: "INSERT",
@@ -56,7 +107,53 @@ func TestRepository(t *testing.T) {
 	}
 }
 
-func readFixture(t *testing.T, fixture string) []byte {
+func TestImageDigest(t *testing.T) {
+	digestTests := []struct {
+		name    string
+		digest  string
+		want    string
+		wantErr string
+	}{
+		{"with digest", "gcr.io/mynamespace/repository@sha256:6ec128e26cd5da4f1f2f6d2bd0e0a4c6b8f1e9f3e5a9e7d4b1c2a3f4e5d6c7b8", "sha256:6ec128e26cd5da4f1f2f6d2bd0e0a4c6b8f1e9f3e5a9e7d4b1c2a3f4e5d6c7b8", ""},
+		{"no digest", "", "", ""},
+		{"invalid digest", "gcr.io/mynamespace/repository@sha256:6ec128e26cd5", "", `failed to parse digest "gcr.io/mynamespace/repository@sha256:6ec128e26cd5"`},
+		{"tag", "gcr.io/mynamespace/repository:latest", "", `no digest in "gcr.io/mynamespace/repository:latest"`},
+	}
+
+	for _, tt := range digestTests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &PushMessage{Action: "INSERT", Digest: tt.digest, Tag: "gcr.io/mynamespace/repository:latest"}
+
+			d, err := hook.ImageDigest()
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if d != tt.want {
+				t.Fatalf("got %s, want %s", d, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventTagWithPort(t *testing.T) {
+	hook := &PushMessage{
+		Action: "INSERT",
+		Tag:    "registry.example.com:5000/mynamespace/repository:v1.0.0",
+	}
+
+	if u := hook.EventRepository(); u != "registry.example.com:5000/mynamespace/repository" {
+		t.Fatalf("got %s, want %s", u, "registry.example.com:5000/mynamespace/repository")
+	}
+	if u := hook.EventTag(); u != "v1.0.0" {
+		t.Fatalf("got %s, want %s", u, "v1.0.0")
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	test.FuzzParser(f, Parse, readFixture(f, "testdata/push_event.json"))
+}
+
+func readFixture(t testing.TB, fixture string) []byte {
 	t.Helper()
 	b, err := ioutil.ReadFile(fixture)
 	if err != nil {
@@ -0,0 +1,113 @@
+package ghcr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/reference"
+)
+
+const (
+	parserName   = "ghcr"
+	registryHost = "ghcr.io"
+)
+
+// Parse parses a payload into a GitHub package event if possible.
+//
+// Both the "package" and the older "registry_package" webhook events are
+// supported, events for other actions, other package types, and pushes of
+// untagged images, are ignored.
+func Parse(payload []byte) ([]hooks.PushEvent, error) {
+	h := &PackageEvent{}
+	err := json.Unmarshal(payload, h)
+	if err != nil {
+		return nil, hooks.NewParseError(parserName, err)
+	}
+	p := h.pkg()
+	if p == nil {
+		return nil, hooks.MissingField(parserName, "package")
+	}
+	if h.Action != "published" || !strings.EqualFold(p.PackageType, "container") {
+		return nil, nil
+	}
+	if p.PackageVersion == nil || p.PackageVersion.ContainerMetadata == nil || p.PackageVersion.ContainerMetadata.Tag == nil {
+		return nil, hooks.MissingField(parserName, "package.package_version.container_metadata.tag")
+	}
+	if p.PackageVersion.ContainerMetadata.Tag.Name == "" {
+		return nil, nil
+	}
+	if _, err := reference.ParseName(fmt.Sprintf("%s/%s", registryHost, h.EventRepository())); err != nil {
+		return nil, hooks.NewParseError(parserName, err)
+	}
+	if err := reference.ValidateTag(h.EventTag()); err != nil {
+		return nil, hooks.NewParseError(parserName, err)
+	}
+	return []hooks.PushEvent{h}, nil
+}
+
+// PackageEvent is a struct for the GitHub package and registry_package events.
+type PackageEvent struct {
+	Action          string   `json:"action"`
+	Package         *Package `json:"package,omitempty"`
+	RegistryPackage *Package `json:"registry_package,omitempty"`
+}
+
+// PushedImageURL is an implementation of the hooks.PushEvent interface.
+func (p PackageEvent) PushedImageURL() string {
+	return fmt.Sprintf("%s/%s:%s", registryHost, p.EventRepository(), p.EventTag())
+}
+
+// EventRepository is an implementation of the hooks.PushEvent interface.
+//
+// Container image names in ghcr.io are always lower-case, even when the owning
+// organisation or user is not.
+func (p PackageEvent) EventRepository() string {
+	pkg := p.pkg()
+	return strings.ToLower(fmt.Sprintf("%s/%s", pkg.Namespace, pkg.Name))
+}
+
+// EventTag is an implementation of the hooks.PushEvent interface.
+func (p PackageEvent) EventTag() string {
+	return p.pkg().PackageVersion.ContainerMetadata.Tag.Name
+}
+
+// ImageDigest returns the digest of the published container image.
+func (p PackageEvent) ImageDigest() (string, error) {
+	return p.pkg().PackageVersion.ContainerMetadata.Tag.Digest, nil
+}
+
+func (p PackageEvent) pkg() *Package {
+	if p.Package != nil {
+		return p.Package
+	}
+	return p.RegistryPackage
+}
+
+// Package is part of the PackageEvent struct.
+type Package struct {
+	Name           string          `json:"name"`
+	Namespace      string          `json:"namespace"`
+	PackageType    string          `json:"package_type"`
+	HTMLURL        string          `json:"html_url"`
+	PackageVersion *PackageVersion `json:"package_version"`
+}
+
+// PackageVersion is part of the Package struct.
+type PackageVersion struct {
+	Version           string             `json:"version"`
+	PackageURL        string             `json:"package_url"`
+	ContainerMetadata *ContainerMetadata `json:"container_metadata"`
+}
+
+// ContainerMetadata is part of the PackageVersion struct.
+type ContainerMetadata struct {
+	Tag *Tag `json:"tag"`
+}
+
+// Tag is part of the ContainerMetadata struct.
+type Tag struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest"`
+}
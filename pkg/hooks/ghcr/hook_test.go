@@ -0,0 +1,176 @@
+package ghcr
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/test"
+)
+
+var _ hooks.PushEvent = (*PackageEvent)(nil)
+var _ hooks.PushEventParser = Parse
+
+func TestParse(t *testing.T) {
+	wantPackage := &Package{
+		Name:        "hello-world",
+		Namespace:   "Octo-Org",
+		PackageType: "CONTAINER",
+		HTMLURL:     "https://github.com/orgs/Octo-Org/packages/container/package/hello-world",
+		PackageVersion: &PackageVersion{
+			Version:    "sha256:4d4c9f6a8cc5ed8fc3d5e1f9b8ec1e6a3d9c5b4a0d8c2e7f1a6b3c9d2e5f8a1b",
+			PackageURL: "ghcr.io/octo-org/hello-world:v1.0.0",
+			ContainerMetadata: &ContainerMetadata{
+				Tag: &Tag{
+					Name:   "v1.0.0",
+					Digest: "sha256:4d4c9f6a8cc5ed8fc3d5e1f9b8ec1e6a3d9c5b4a0d8c2e7f1a6b3c9d2e5f8a1b",
+				},
+			},
+		},
+	}
+
+	parseTests := []struct {
+		fixture string
+		want    *PackageEvent
+	}{
+		{"testdata/package_event.json", &PackageEvent{Action: "published", Package: wantPackage}},
+		{"testdata/registry_package_event.json", &PackageEvent{Action: "published", RegistryPackage: wantPackage}},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			hook, err := Parse(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff([]hooks.PushEvent{tt.want}, hook); diff != "" {
+				t.Fatalf("hook doesn't match:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseWithInvalidEvents(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		payload string
+		wantErr string
+	}{
+		{"invalid JSON", `{"action":`, "failed to parse ghcr payload: unexpected end of JSON input"},
+		{"no package", `{"action":"published"}`, "missing required field: package"},
+		{"no metadata", `{"action":"published","package":{"package_type":"CONTAINER","package_version":{}}}`, "missing required field: package.package_version.container_metadata.tag"},
+		{"invalid name", `{"action":"published","package":{"name":"hello world","namespace":"octo-org","package_type":"CONTAINER","package_version":{"container_metadata":{"tag":{"name":"v1"}}}}}`, "invalid repository name"},
+		{"invalid tag", `{"action":"published","package":{"name":"hello-world","namespace":"octo-org","package_type":"CONTAINER","package_version":{"container_metadata":{"tag":{"name":"-v1"}}}}}`, "invalid tag format"},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.payload))
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if !hooks.IsParseError(err) {
+				t.Fatalf("got error %#v, want a ParseError", err)
+			}
+		})
+	}
+}
+
+func TestParseIgnoredEvents(t *testing.T) {
+	ignoredTests := []struct {
+		name    string
+		payload string
+	}{
+		{"untagged", `{"action":"published","package":{"package_type":"CONTAINER","package_version":{"container_metadata":{"tag":{"name":"","digest":"sha256:4d4c"}}}}}`},
+		{"updated", `{"action":"updated","package":{"name":"hello-world","namespace":"octo-org","package_type":"CONTAINER","package_version":{"container_metadata":{"tag":{"name":"v1"}}}}}`},
+		{"updated without metadata", `{"action":"updated","package":{"name":"hello-world","namespace":"octo-org","package_type":"CONTAINER","package_version":{"version":"1.0.0"}}}`},
+		{"npm package", `{"action":"published","package":{"name":"hello-world","namespace":"octo-org","package_type":"npm","package_version":{"version":"1.0.0","package_url":"https://npm.pkg.github.com/@octo-org/hello-world@1.0.0"}}}`},
+		{"maven package", `{"action":"published","registry_package":{"name":"hello-world","namespace":"octo-org","package_type":"maven","package_version":{"version":"1.0.0"}}}`},
+	}
+
+	for _, tt := range ignoredTests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := Parse([]byte(tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 0 {
+				t.Fatalf("got %#v, want no events", events)
+			}
+		})
+	}
+}
+
+func TestPushedImageURL(t *testing.T) {
+	hook := createHook()
+	want := "ghcr.io/octo-org/hello-world:v1.0.0"
+
+	if u := hook.PushedImageURL(); u != want {
+		t.Fatalf("got %s, want %s", u, want)
+	}
+}
+
+func TestEventRepository(t *testing.T) {
+	hook := createHook()
+	want := "octo-org/hello-world"
+
+	if u := hook.EventRepository(); u != want {
+		t.Fatalf("got %s, want %s", u, want)
+	}
+}
+
+func TestEventTag(t *testing.T) {
+	hook := createHook()
+	want := "v1.0.0"
+
+	if u := hook.EventTag(); u != want {
+		t.Fatalf("got %s, want %s", u, want)
+	}
+}
+
+func TestImageDigest(t *testing.T) {
+	hook := createHook()
+	want := "sha256:4d4c"
+
+	u, err := hook.ImageDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != want {
+		t.Fatalf("got %s, want %s", u, want)
+	}
+}
+
+func createHook() *PackageEvent {
+	return &PackageEvent{
+		Action: "published",
+		RegistryPackage: &Package{
+			Name:        "hello-world",
+			Namespace:   "Octo-Org",
+			PackageType: "container",
+			PackageVersion: &PackageVersion{
+				ContainerMetadata: &ContainerMetadata{
+					Tag: &Tag{Name: "v1.0.0", Digest: "sha256:4d4c"},
+				},
+			},
+		},
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	test.FuzzParser(f, Parse,
+		readFixture(f, "testdata/package_event.json"),
+		readFixture(f, "testdata/registry_package_event.json"))
+}
+
+func readFixture(t testing.TB, fixture string) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", fixture, err)
+	}
+	return b
+}
@@ -0,0 +1,124 @@
+package harbor
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/reference"
+)
+
+// PushArtifact is the event type sent by Harbor when an artifact is pushed.
+const PushArtifact = "PUSH_ARTIFACT"
+
+const parserName = "harbor"
+
+// Parse parses a payload into a Harbor artifact push if possible.
+//
+// A PushEvent is returned for each tagged resource in the event.
+//
+// Events other than PUSH_ARTIFACT e.g. scanning, deletion and quota events are
+// ignored, and no PushEvents are returned.
+func Parse(payload []byte) ([]hooks.PushEvent, error) {
+	h := &Webhook{}
+	err := json.Unmarshal(payload, h)
+	if err != nil {
+		return nil, hooks.NewParseError(parserName, err)
+	}
+	if h.Type == "" {
+		return nil, hooks.MissingField(parserName, "type")
+	}
+	if h.Type != PushArtifact {
+		return nil, nil
+	}
+	if h.EventData == nil || h.EventData.Repository == nil || h.EventData.Repository.RepoFullName == "" {
+		return nil, hooks.MissingField(parserName, "event_data.repository.repo_full_name")
+	}
+	return h.artifacts()
+}
+
+func validate(r *Resource) error {
+	if r.ResourceURL == "" {
+		return hooks.MissingField(parserName, "event_data.resources.resource_url")
+	}
+	ref, err := reference.Parse(r.ResourceURL)
+	if err != nil {
+		return hooks.NewParseError(parserName, err)
+	}
+	if ref.Tag != r.Tag {
+		return hooks.NewParseError(parserName, fmt.Errorf("resource URL %q does not match tag %q", r.ResourceURL, r.Tag))
+	}
+	return nil
+}
+
+// Webhook is a struct for the Harbor webhook event.
+type Webhook struct {
+	Type      string     `json:"type"`
+	OccurAt   int64      `json:"occur_at"`
+	Operator  string     `json:"operator"`
+	EventData *EventData `json:"event_data"`
+}
+
+// artifacts returns an ArtifactPush for each of the tagged resources in the
+// event, untagged resources are skipped.
+func (w Webhook) artifacts() ([]hooks.PushEvent, error) {
+	var pushes []hooks.PushEvent
+	for _, r := range w.EventData.Resources {
+		if r == nil || r.Tag == "" {
+			continue
+		}
+		if err := validate(r); err != nil {
+			return nil, err
+		}
+		pushes = append(pushes, &ArtifactPush{Repository: w.EventData.Repository, Resource: r})
+	}
+	return pushes, nil
+}
+
+// EventData is part of the Webhook struct.
+type EventData struct {
+	Resources  []*Resource `json:"resources"`
+	Repository *Repository `json:"repository"`
+}
+
+// Resource is part of the EventData struct.
+type Resource struct {
+	Digest      string `json:"digest"`
+	Tag         string `json:"tag"`
+	ResourceURL string `json:"resource_url"`
+}
+
+// Repository is part of the EventData struct.
+type Repository struct {
+	DateCreated  int64  `json:"date_created"`
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace"`
+	RepoFullName string `json:"repo_full_name"`
+	RepoType     string `json:"repo_type"`
+}
+
+// ArtifactPush is a single tagged artifact from a Harbor push event.
+type ArtifactPush struct {
+	Repository *Repository
+	Resource   *Resource
+}
+
+// PushedImageURL is an implementation of the hooks.PushEvent interface.
+func (p ArtifactPush) PushedImageURL() string {
+	return p.Resource.ResourceURL
+}
+
+// EventRepository is an implementation of the hooks.PushEvent interface.
+func (p ArtifactPush) EventRepository() string {
+	return p.Repository.RepoFullName
+}
+
+// EventTag is an implementation of the hooks.PushEvent interface.
+func (p ArtifactPush) EventTag() string {
+	return p.Resource.Tag
+}
+
+// ImageDigest returns the digest of the pushed artifact.
+func (p ArtifactPush) ImageDigest() (string, error) {
+	return p.Resource.Digest, nil
+}
@@ -0,0 +1,163 @@
+package harbor
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/test"
+)
+
+var _ hooks.PushEvent = (*ArtifactPush)(nil)
+var _ hooks.PushEventParser = Parse
+
+func TestParse(t *testing.T) {
+	req := readFixture(t, "testdata/push_artifact.json")
+
+	hook, err := Parse(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repository := &Repository{
+		DateCreated:  1680501893,
+		Name:         "nginx",
+		Namespace:    "library",
+		RepoFullName: "library/nginx",
+		RepoType:     "private",
+	}
+	want := []hooks.PushEvent{
+		&ArtifactPush{
+			Repository: repository,
+			Resource: &Resource{
+				Digest:      "sha256:954b378c375d852eb3c63ab88978f640b4348b01c1b3456a024a81536dafbbf4",
+				Tag:         "v1.2.3",
+				ResourceURL: "harbor.example.com/library/nginx:v1.2.3",
+			},
+		},
+		&ArtifactPush{
+			Repository: repository,
+			Resource: &Resource{
+				Digest:      "sha256:954b378c375d852eb3c63ab88978f640b4348b01c1b3456a024a81536dafbbf4",
+				Tag:         "latest",
+				ResourceURL: "harbor.example.com/library/nginx:latest",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, hook); diff != "" {
+		t.Fatalf("hook doesn't match:\n%s", diff)
+	}
+}
+
+func TestParseIgnoresNonPushEvents(t *testing.T) {
+	req := readFixture(t, "testdata/scanning_completed.json")
+
+	hook, err := Parse(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hook) != 0 {
+		t.Fatalf("got %#v, want no events", hook)
+	}
+}
+
+func TestParseSkipsUntaggedResources(t *testing.T) {
+	hook, err := Parse([]byte(`{"type":"PUSH_ARTIFACT","event_data":{"resources":[{"digest":"sha256:954b"},{"digest":"sha256:954b","tag":"v1","resource_url":"harbor.example.com/library/nginx:v1"}],"repository":{"repo_full_name":"library/nginx"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(hook); l != 1 {
+		t.Fatalf("got %d events, want 1", l)
+	}
+	if tag := hook[0].EventTag(); tag != "v1" {
+		t.Fatalf("got %s, want %s", tag, "v1")
+	}
+}
+
+func TestParseWithInvalidEvents(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		payload string
+		wantErr string
+	}{
+		{"invalid JSON", `[]`, "failed to parse harbor payload: json: cannot unmarshal array"},
+		{"no type", `{}`, "missing required field: type"},
+		{"no repository", `{"type":"PUSH_ARTIFACT","event_data":{"resources":[]}}`, "missing required field: event_data.repository.repo_full_name"},
+		{"no resource URL", `{"type":"PUSH_ARTIFACT","event_data":{"resources":[{"tag":"v1"}],"repository":{"repo_full_name":"library/nginx"}}}`, "missing required field: event_data.resources.resource_url"},
+		{"invalid resource URL", `{"type":"PUSH_ARTIFACT","event_data":{"resources":[{"tag":"v1","resource_url":"harbor.example.com/library/NGINX:v1"}],"repository":{"repo_full_name":"library/nginx"}}}`, "invalid repository name"},
+		{"mismatched tag", `{"type":"PUSH_ARTIFACT","event_data":{"resources":[{"tag":"v1","resource_url":"harbor.example.com/library/nginx:v2"}],"repository":{"repo_full_name":"library/nginx"}}}`, `resource URL ".*" does not match tag "v1"`},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.payload))
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if !hooks.IsParseError(err) {
+				t.Fatalf("got error %#v, want a ParseError", err)
+			}
+		})
+	}
+}
+
+func TestPushedImageURL(t *testing.T) {
+	hook := createHook()
+	want := "harbor.example.com/library/nginx:v1.2.3"
+
+	if u := hook.PushedImageURL(); u != want {
+		t.Fatalf("got %s, want %s", u, want)
+	}
+}
+
+func TestEventRepository(t *testing.T) {
+	hook := createHook()
+	want := "library/nginx"
+
+	if u := hook.EventRepository(); u != want {
+		t.Fatalf("got %s, want %s", u, want)
+	}
+}
+
+func TestEventTag(t *testing.T) {
+	hook := createHook()
+	want := "v1.2.3"
+
+	if u := hook.EventTag(); u != want {
+		t.Fatalf("got %s, want %s", u, want)
+	}
+}
+
+func createHook() *ArtifactPush {
+	return &ArtifactPush{
+		Repository: &Repository{
+			Name:         "nginx",
+			Namespace:    "library",
+			RepoFullName: "library/nginx",
+		},
+		Resource: &Resource{
+			Digest:      "sha256:954b",
+			Tag:         "v1.2.3",
+			ResourceURL: "harbor.example.com/library/nginx:v1.2.3",
+		},
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	test.FuzzParser(f, Parse,
+		readFixture(f, "testdata/push_artifact.json"),
+		readFixture(f, "testdata/scanning_completed.json"))
+}
+
+func readFixture(t testing.TB, fixture string) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(fixture)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", fixture, err)
+	}
+	return b
+}
@@ -1,11 +1,22 @@
 package hooks
 
 // PushEvent values return the image that is to be inserted into the file.
+//
+// ImageDigest returns the digest of the pushed image e.g. sha256:..., or an
+// empty string if the hook doesn't provide one, and an error if the hook
+// provides a digest that can't be parsed.
 type PushEvent interface {
 	PushedImageURL() string
 	EventRepository() string
 	EventTag() string
+	ImageDigest() (string, error)
 }
 
 // PushEventParser parses the specifics of a hook request into a body.
-type PushEventParser func(payload []byte) (PushEvent, error)
+//
+// A single payload can notify several pushes e.g. multiple tags, and so
+// parsers return a PushEvent for each of them.
+//
+// Parsers return no PushEvents and no error for events that are valid but
+// should be ignored e.g. events other than image pushes.
+type PushEventParser func(payload []byte) ([]PushEvent, error)
@@ -5,16 +5,30 @@ import (
 	"fmt"
 
 	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/reference"
 )
 
+const parserName = "quay"
+
 // Parse parses a payload it into a Quay.io Push hook if possible.
-func Parse(payload []byte) (hooks.PushEvent, error) {
+//
+// A PushEvent is returned for each of the updated tags in the hook.
+func Parse(payload []byte) ([]hooks.PushEvent, error) {
 	h := &RepositoryPushHook{}
 	err := json.Unmarshal(payload, h)
 	if err != nil {
+		return nil, hooks.NewParseError(parserName, err)
+	}
+	if err := h.validate(); err != nil {
 		return nil, err
 	}
-	return h, nil
+	events := make([]hooks.PushEvent, len(h.UpdatedTags))
+	for i, tag := range h.UpdatedTags {
+		tagged := *h
+		tagged.UpdatedTags = []string{tag}
+		events[i] = &tagged
+	}
+	return events, nil
 }
 
 // RepositoryPushHook is a struct for the Quay.io push event.
@@ -29,7 +43,7 @@ type RepositoryPushHook struct {
 
 // PushedImageURL is an implementation of the hooks.PushEvent interface.
 func (p RepositoryPushHook) PushedImageURL() string {
-	return fmt.Sprintf("%s:%s", p.DockerURL, p.UpdatedTags[0])
+	return fmt.Sprintf("%s:%s", p.DockerURL, p.EventTag())
 }
 
 // EventRepository is an implementation of the hooks.PushEvent interface.
@@ -39,5 +53,36 @@ func (p RepositoryPushHook) EventRepository() string {
 
 // EventTag is an implementation of the hooks.PushEvent interface.
 func (p RepositoryPushHook) EventTag() string {
+	if len(p.UpdatedTags) == 0 {
+		return ""
+	}
 	return p.UpdatedTags[0]
 }
+
+// ImageDigest is an implementation of the hooks.PushEvent interface.
+//
+// Quay repository push hooks don't include the digest of the pushed images.
+func (p RepositoryPushHook) ImageDigest() (string, error) {
+	return "", nil
+}
+
+func (p RepositoryPushHook) validate() error {
+	if p.Repository == "" {
+		return hooks.MissingField(parserName, "repository")
+	}
+	if p.DockerURL == "" {
+		return hooks.MissingField(parserName, "docker_url")
+	}
+	if _, err := reference.ParseName(p.DockerURL); err != nil {
+		return hooks.NewParseError(parserName, err)
+	}
+	if len(p.UpdatedTags) == 0 {
+		return hooks.MissingField(parserName, "updated_tags")
+	}
+	for _, tag := range p.UpdatedTags {
+		if err := reference.ValidateTag(tag); err != nil {
+			return hooks.NewParseError(parserName, err)
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/test"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -19,19 +20,64 @@ func TestParse(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	want := &RepositoryPushHook{
-		Name:        "repository",
-		Repository:  "mynamespace/repository",
-		Namespace:   "mynamespace",
-		DockerURL:   "quay.io/mynamespace/repository",
-		Homepage:    "https://quay.io/repository/mynamespace/repository",
-		UpdatedTags: []string{"latest"},
+	want := []hooks.PushEvent{
+		&RepositoryPushHook{
+			Name:        "repository",
+			Repository:  "mynamespace/repository",
+			Namespace:   "mynamespace",
+			DockerURL:   "quay.io/mynamespace/repository",
+			Homepage:    "https://quay.io/repository/mynamespace/repository",
+			UpdatedTags: []string{"latest"},
+		},
 	}
 	if diff := cmp.Diff(want, hook); diff != "" {
 		t.Fatalf("hook doesn't match:\n%s", diff)
 	}
 }
 
+func TestParseWithMultipleTags(t *testing.T) {
+	events, err := Parse([]byte(`{"repository":"mynamespace/repository","docker_url":"quay.io/mynamespace/repository","updated_tags":["v1.2.3","latest"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, h := range events {
+		got = append(got, h.PushedImageURL())
+	}
+	want := []string{"quay.io/mynamespace/repository:v1.2.3", "quay.io/mynamespace/repository:latest"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("hooks don't match:\n%s", diff)
+	}
+}
+
+func TestParseWithInvalidHooks(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		payload string
+		wantErr string
+	}{
+		{"invalid JSON", `{"repository":`, "failed to parse quay payload: unexpected end of JSON input"},
+		{"no repository", `{"docker_url":"quay.io/mynamespace/repository","updated_tags":["latest"]}`, "missing required field: repository"},
+		{"no docker_url", `{"repository":"mynamespace/repository","updated_tags":["latest"]}`, "missing required field: docker_url"},
+		{"invalid docker_url", `{"repository":"mynamespace/repository","docker_url":"quay.io/mynamespace/repository:latest","updated_tags":["latest"]}`, "is not a repository name"},
+		{"no updated tags", `{"repository":"mynamespace/repository","docker_url":"quay.io/mynamespace/repository","updated_tags":[]}`, "missing required field: updated_tags"},
+		{"invalid tag", `{"repository":"mynamespace/repository","docker_url":"quay.io/mynamespace/repository","updated_tags":["latest",""]}`, "invalid tag format"},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.payload))
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if !hooks.IsParseError(err) {
+				t.Fatalf("got error %#v, want a ParseError", err)
+			}
+		})
+	}
+}
+
 func TestPushedImageURL(t *testing.T) {
 	hook := &RepositoryPushHook{
 		Name:        "repository",
@@ -73,7 +119,22 @@ func TestEventTag(t *testing.T) {
 	}
 }
 
-func readFixture(t *testing.T, fixture string) []byte {
+func TestEventTagWithNoTags(t *testing.T) {
+	hook := &RepositoryPushHook{
+		Repository: "mynamespace/repository",
+		DockerURL:  "quay.io/mynamespace/repository",
+	}
+
+	if u := hook.EventTag(); u != "" {
+		t.Fatalf("got %s, want an empty tag", u)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	test.FuzzParser(f, Parse, readFixture(f, "testdata/push_hook.json"))
+}
+
+func readFixture(t testing.TB, fixture string) []byte {
 	t.Helper()
 	b, err := ioutil.ReadFile(fixture)
 	if err != nil {
@@ -0,0 +1,44 @@
+package keylock
+
+import "sync"
+
+// Locker provides a mutex for each key, so that work can be serialized per
+// key, while work for different keys runs concurrently.
+type Locker struct {
+	mu    sync.Mutex
+	locks map[string]*lock
+}
+
+type lock struct {
+	sync.Mutex
+	refs int
+}
+
+// New creates and returns a new Locker.
+func New() *Locker {
+	return &Locker{locks: map[string]*lock{}}
+}
+
+// Lock blocks until the lock for the key is acquired, and returns a function
+// that releases it.
+func (k *Locker) Lock(key string) func() {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &lock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(k.locks, key)
+		}
+	}
+}
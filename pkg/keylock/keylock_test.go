@@ -0,0 +1,59 @@
+package keylock
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockSerializesPerKey(t *testing.T) {
+	k := New()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := k.Lock("test")
+			defer unlock()
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning != 1 {
+		t.Fatalf("got %d concurrent holders of the lock, want 1", maxRunning)
+	}
+	if l := len(k.locks); l != 0 {
+		t.Fatalf("got %d locks after unlocking, want 0", l)
+	}
+}
+
+func TestLockWithDifferentKeys(t *testing.T) {
+	k := New()
+	unlock := k.Lock("first")
+	defer unlock()
+
+	done := make(chan struct{})
+	go func() {
+		k.Lock("second")()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock for a different key was blocked")
+	}
+}
@@ -5,10 +5,14 @@ import (
 
 	"github.com/gitops-tools/image-updater/pkg/applier"
 	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/retry"
 	"github.com/go-logr/logr"
 )
 
 // Handler parses and processes pubsub messages.
+//
+// Messages can be handled concurrently, the applier serializes updates to the
+// same file.
 type Handler struct {
 	applier *applier.Applier
 	log     logr.Logger
@@ -20,20 +24,39 @@ func New(logger logr.Logger, u *applier.Applier, p hooks.PushEventParser) *Handl
 	return &Handler{log: logger, applier: u, parser: p}
 }
 
-// Handle acks, parses and processes pubsub messages
+// Handle parses and processes pubsub messages, and acks or nacks them.
+//
+// Messages are acked when all the events in them were processed, and when
+// they will never be processed e.g. they can't be parsed, redelivering these
+// would fail again.
+//
+// If processing any of the events fails with a transient error, e.g. the Git
+// service is unavailable, the message is nacked so that it is redelivered,
+// messages that fail with permanent errors are acked.
 func (h *Handler) Handle(ctx context.Context, m message) {
 	h.log.Info("processing hook request")
 
-	hook, err := h.parser(m.Data())
+	events, err := h.parser(m.Data())
 	if err != nil {
 		h.log.Error(err, "failed to parse request")
+		m.Ack()
+		return
+	}
+	if len(events) == 0 {
+		h.log.Info("ignoring hook request")
+		m.Ack()
 		return
 	}
 
-	err = h.applier.UpdateFromHook(ctx, hook)
-
-	if err != nil {
-		h.log.Error(err, "hook update failed")
+	transient := false
+	for _, hook := range events {
+		if err := h.applier.UpdateFromHook(ctx, hook); err != nil {
+			h.log.Error(err, "hook update failed", "repository", hook.EventRepository(), "tag", hook.EventTag(), "transient", retry.IsTransient(err))
+			transient = transient || retry.IsTransient(err)
+		}
+	}
+	if transient {
+		m.Nack()
 		return
 	}
 
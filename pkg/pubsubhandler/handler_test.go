@@ -2,9 +2,12 @@ package pubsubhandler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/gitops-tools/pkg/client/mock"
 	"github.com/gitops-tools/pkg/updater"
@@ -15,7 +18,9 @@ import (
 
 	"github.com/gitops-tools/image-updater/pkg/applier"
 	"github.com/gitops-tools/image-updater/pkg/config"
+	"github.com/gitops-tools/image-updater/pkg/hooks"
 	"github.com/gitops-tools/image-updater/pkg/hooks/gcr"
+	"github.com/gitops-tools/image-updater/pkg/retry"
 )
 
 const (
@@ -44,6 +49,122 @@ func TestHandler(t *testing.T) {
 		Base:  "master",
 		Title: "Automated image update",
 	})
+	assertAcked(t, msg)
+}
+
+func TestHandlerWithPinDigest(t *testing.T) {
+	testSHA := "980a0d5f19a64b4b30a87d4206aade58726b60e3"
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	m.AddBranchHead(testGitHubRepo, "master", testSHA)
+	m.AddFileContents(testGitHubRepo, testFilePath, "master", []byte("test:\n  image: old-image\n"))
+	configs := createConfigs()
+	configs.Repositories[0].PinDigest = config.PinDigestOnly
+	applier := applier.New(logger, m, configs, updater.NameGenerator(stubNameGenerator{"a"}))
+
+	h := New(logger, applier, gcr.Parse)
+
+	msg := readFixture(t, "testdata/push_event_with_digest.json")
+
+	h.Handle(context.TODO(), msg)
+
+	want := "test:\n  image: gcr.io/mynamespace/repository@sha256:6ec128e26cd5da4f1f2f6d2bd0e0a4c6b8f1e9f3e5a9e7d4b1c2a3f4e5d6c7b8\n"
+	if s := string(m.GetUpdatedContents(testGitHubRepo, testFilePath, "test-branch-a")); s != want {
+		t.Fatalf("update failed, got %#v, want %#v", s, want)
+	}
+	assertAcked(t, msg)
+}
+
+func TestHandlerWithParseFailure(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+	badParser := func(payload []byte) ([]hooks.PushEvent, error) {
+		return nil, errors.New("failed")
+	}
+
+	h := New(logger, applier, badParser)
+
+	msg := readFixture(t, "testdata/push_event.json")
+
+	h.Handle(context.TODO(), msg)
+
+	m.AssertNoInteractions()
+	assertAcked(t, msg)
+}
+
+func TestHandlerWithNoMatchingConfig(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	applier := applier.New(logger, m, &config.RepoConfiguration{}, updater.NameGenerator(stubNameGenerator{"a"}))
+
+	h := New(logger, applier, gcr.Parse)
+
+	msg := readFixture(t, "testdata/push_event.json")
+
+	h.Handle(context.TODO(), msg)
+
+	m.AssertNoInteractions()
+	assertAcked(t, msg)
+}
+
+func TestHandlerWithFailureToUpdate(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	m.GetFileErr = errors.New(http.StatusText(http.StatusBadGateway))
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+	applier.SetBackoff(retry.Backoff{Attempts: 2, Initial: time.Millisecond, Max: time.Second})
+
+	h := New(logger, applier, gcr.Parse)
+
+	msg := readFixture(t, "testdata/push_event.json")
+
+	h.Handle(context.TODO(), msg)
+
+	m.AssertNoPullRequestsCreated()
+	if msg.acked || !msg.nacked {
+		t.Fatalf("got acked %v, nacked %v, want the message to be nacked", msg.acked, msg.nacked)
+	}
+}
+
+func TestHandlerWithPermanentFailureToUpdate(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+
+	h := New(logger, applier, gcr.Parse)
+
+	msg := readFixture(t, "testdata/push_event.json")
+
+	h.Handle(context.TODO(), msg)
+
+	m.AssertNoPullRequestsCreated()
+	assertAcked(t, msg)
+}
+
+func TestHandlerWithIgnoredEvent(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	m := mock.New(t)
+	applier := applier.New(logger, m, createConfigs(), updater.NameGenerator(stubNameGenerator{"a"}))
+	ignoringParser := func(payload []byte) ([]hooks.PushEvent, error) {
+		return nil, nil
+	}
+
+	h := New(logger, applier, ignoringParser)
+
+	msg := readFixture(t, "testdata/push_event.json")
+
+	h.Handle(context.TODO(), msg)
+
+	m.AssertNoInteractions()
+	assertAcked(t, msg)
+}
+
+func assertAcked(t *testing.T, m *stubMessage) {
+	t.Helper()
+	if !m.acked || m.nacked {
+		t.Fatalf("got acked %v, nacked %v, want the message to be acked", m.acked, m.nacked)
+	}
 }
 
 func readFixture(t *testing.T, fixture string) *stubMessage {
@@ -74,10 +195,13 @@ func createConfigs() *config.RepoConfiguration {
 }
 
 type stubMessage struct {
-	data []byte
+	data   []byte
+	acked  bool
+	nacked bool
 }
 
-func (m *stubMessage) Ack()         {}
+func (m *stubMessage) Ack()         { m.acked = true }
+func (m *stubMessage) Nack()        { m.nacked = true }
 func (m *stubMessage) Data() []byte { return m.data }
 
 type stubNameGenerator struct {
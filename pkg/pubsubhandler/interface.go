@@ -2,5 +2,6 @@ package pubsubhandler
 
 type message interface {
 	Ack()
+	Nack()
 	Data() []byte
 }
@@ -0,0 +1,190 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/retry"
+)
+
+var (
+	// ErrQueueFull is returned when events can't be queued because the queue
+	// is at capacity.
+	ErrQueueFull = errors.New("queue is full")
+
+	// ErrStopped is returned when events are queued after the queue has been
+	// stopped.
+	ErrStopped = errors.New("queue is stopped")
+)
+
+// DefaultRetries is the default configuration for retrying events that fail
+// with transient errors, the delays are longer than the applier's, because
+// the applier has already retried the Git service calls.
+var DefaultRetries = retry.Backoff{Attempts: 5, Initial: 30 * time.Second, Max: 10 * time.Minute}
+
+// Updater is implemented by values that can process push events e.g. the
+// applier.Applier.
+type Updater interface {
+	UpdateFromHook(ctx context.Context, h hooks.PushEvent) error
+}
+
+// item is the set of events from a single hook.
+type item struct {
+	id      string
+	events  []hooks.PushEvent
+	attempt int
+}
+
+// Queue is a bounded queue of push events that are processed by a pool of
+// workers.
+//
+// Events that fail with transient errors are queued again after a delay,
+// until the attempts are exhausted.
+type Queue struct {
+	log     logr.Logger
+	updater Updater
+	items   chan item
+	retries retry.Backoff
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+// New creates and returns a new Queue that can hold up to size hooks waiting
+// to be processed.
+func New(l logr.Logger, u Updater, size int) *Queue {
+	return &Queue{log: l, updater: u, items: make(chan item, size), retries: DefaultRetries, done: make(chan struct{})}
+}
+
+// SetRetries configures how events that fail with transient errors are
+// retried, the attempts include the first.
+func (q *Queue) SetRetries(b retry.Backoff) {
+	q.retries = b
+}
+
+// Enqueue adds the events from a hook to the queue and returns an ID that
+// identifies them in the logs.
+//
+// If the queue is full, ErrQueueFull is returned, and the events are not
+// queued.
+func (q *Queue) Enqueue(events []hooks.PushEvent) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	if err := q.push(item{id: id, events: events}); err != nil {
+		return "", err
+	}
+	q.log.Info("queued hook events", "eventID", id, "count", len(events))
+	return id, nil
+}
+
+func (q *Queue) push(it item) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.stopped {
+		return ErrStopped
+	}
+	select {
+	case q.items <- it:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Start starts the workers that process the queued events, the context is
+// passed to the Updater.
+func (q *Queue) Start(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for it := range q.items {
+				q.process(ctx, it)
+			}
+		}()
+	}
+}
+
+// Stop stops accepting new events, and waits for the workers to process the
+// events that are already queued, events that are waiting to be retried are
+// dropped.
+func (q *Queue) Stop() {
+	q.mu.Lock()
+	if !q.stopped {
+		q.stopped = true
+		close(q.done)
+		close(q.items)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *Queue) process(ctx context.Context, it item) {
+	var failed []hooks.PushEvent
+	var retryAfter time.Duration
+	for _, h := range it.events {
+		if err := q.updater.UpdateFromHook(ctx, h); err != nil {
+			q.log.Error(err, "hook update failed", "eventID", it.id, "repository", h.EventRepository(), "tag", h.EventTag(), "transient", retry.IsTransient(err))
+			if retry.IsTransient(err) {
+				failed = append(failed, h)
+				if d := retry.RetryAfter(err); d > retryAfter {
+					retryAfter = d
+				}
+			}
+			continue
+		}
+		q.log.Info("processed hook event", "eventID", it.id, "repository", h.EventRepository(), "tag", h.EventTag())
+	}
+	if len(failed) > 0 {
+		q.retry(item{id: it.id, events: failed, attempt: it.attempt + 1}, retryAfter)
+	}
+}
+
+// retry queues the events again after a delay which doubles for each
+// attempt, or the delay requested by the Git service if it's longer.
+func (q *Queue) retry(it item, retryAfter time.Duration) {
+	if it.attempt >= q.retries.Attempts {
+		q.log.Info("dropping hook events, retries exhausted", "eventID", it.id, "count", len(it.events), "attempts", it.attempt)
+		return
+	}
+	delay := q.retries.Initial << (it.attempt - 1)
+	if delay > q.retries.Max || delay <= 0 {
+		delay = q.retries.Max
+	}
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	q.log.Info("retrying hook events", "eventID", it.id, "count", len(it.events), "attempt", it.attempt, "delay", delay)
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		select {
+		case <-q.done:
+			q.log.Info("dropping hook events, queue stopped", "eventID", it.id, "count", len(it.events))
+		case <-time.After(delay):
+			if err := q.push(it); err != nil {
+				q.log.Error(err, "failed to retry hook events", "eventID", it.id, "count", len(it.events))
+			}
+		}
+	}()
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate an event ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
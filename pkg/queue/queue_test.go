@@ -0,0 +1,151 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/hooks/quay"
+	"github.com/gitops-tools/image-updater/pkg/retry"
+)
+
+func TestQueue(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	u := &recordingUpdater{}
+	q := New(logger, u, 10)
+
+	id1, err := q.Enqueue([]hooks.PushEvent{makeHook("v1"), makeHook("v2")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id2, err := q.Enqueue([]hooks.PushEvent{makeHook("v3")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start(context.TODO(), 1)
+	q.Stop()
+
+	if id1 == "" || id1 == id2 {
+		t.Fatalf("got IDs %q and %q, want unique IDs", id1, id2)
+	}
+	if diff := cmp.Diff([]string{"v1", "v2", "v3"}, u.tags); diff != "" {
+		t.Fatalf("processed events don't match:\n%s", diff)
+	}
+}
+
+func TestQueueContinuesAfterFailures(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	u := &recordingUpdater{err: errors.New("failed")}
+	q := New(logger, u, 10)
+	q.Start(context.TODO(), 2)
+
+	for _, tag := range []string{"v1", "v2"} {
+		if _, err := q.Enqueue([]hooks.PushEvent{makeHook(tag)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Stop()
+
+	if l := len(u.tags); l != 2 {
+		t.Fatalf("got %d processed events, want 2", l)
+	}
+}
+
+func TestQueueRetriesTransientFailures(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	transient := &retry.Error{Err: errors.New("unavailable"), Transient: true}
+	u := &recordingUpdater{errs: []error{transient, transient}, processed: make(chan string, 10)}
+	q := New(logger, u, 10)
+	q.SetRetries(retry.Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Second})
+	q.Start(context.TODO(), 1)
+	defer q.Stop()
+
+	if _, err := q.Enqueue([]hooks.PushEvent{makeHook("v1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case tag := <-u.processed:
+		if tag != "v1" {
+			t.Fatalf("got processed tag %q, want %q", tag, "v1")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the event to be retried")
+	}
+	if diff := cmp.Diff([]string{"v1", "v1", "v1"}, u.calls()); diff != "" {
+		t.Fatalf("processed events don't match:\n%s", diff)
+	}
+}
+
+func TestQueueWhenFull(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	q := New(logger, &recordingUpdater{}, 1)
+
+	if _, err := q.Enqueue([]hooks.PushEvent{makeHook("v1")}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := q.Enqueue([]hooks.PushEvent{makeHook("v2")})
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("got error %v, want %v", err, ErrQueueFull)
+	}
+}
+
+func TestQueueWhenStopped(t *testing.T) {
+	logger := zapr.NewLogger(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)))
+	q := New(logger, &recordingUpdater{}, 1)
+	q.Stop()
+
+	_, err := q.Enqueue([]hooks.PushEvent{makeHook("v1")})
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("got error %v, want %v", err, ErrStopped)
+	}
+}
+
+func makeHook(tag string) hooks.PushEvent {
+	return &quay.RepositoryPushHook{
+		Repository:  "mynamespace/repository",
+		DockerURL:   "quay.io/mynamespace/repository",
+		UpdatedTags: []string{tag},
+	}
+}
+
+// recordingUpdater records the tags of the events that it's called with, and
+// returns the errors in errs for the first calls, and then err.
+//
+// If processed is not nil, the tags of events that are processed without an
+// error are sent to it.
+type recordingUpdater struct {
+	sync.Mutex
+	tags      []string
+	errs      []error
+	err       error
+	processed chan string
+}
+
+func (r *recordingUpdater) UpdateFromHook(ctx context.Context, h hooks.PushEvent) error {
+	r.Lock()
+	defer r.Unlock()
+	r.tags = append(r.tags, h.EventTag())
+	err := r.err
+	if len(r.errs) > 0 {
+		err, r.errs = r.errs[0], r.errs[1:]
+	}
+	if err == nil && r.processed != nil {
+		r.processed <- h.EventTag()
+	}
+	return err
+}
+
+func (r *recordingUpdater) calls() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string{}, r.tags...)
+}
@@ -0,0 +1,154 @@
+package reference
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// These follow the grammar for references in the Docker Distribution project.
+//
+//	reference    := name [ ":" tag ] [ "@" digest ]
+//	name         := [domain '/'] path-component ['/' path-component]*
+//	domain       := host [':' port-number]
+//	tag          := /[\w][\w.-]{0,127}/
+//	digest       := algorithm ":" hex
+const (
+	alphanumeric    = `[a-z0-9]+`
+	separator       = `(?:[._]|__|[-]+)`
+	pathComponent   = alphanumeric + `(?:` + separator + alphanumeric + `)*`
+	domainComponent = `(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])`
+	domainName      = domainComponent + `(?:\.` + domainComponent + `)*`
+	ipv6Address     = `\[(?:[a-fA-F0-9:]+)\]`
+	domainAndPort   = `(?:` + domainName + `|` + ipv6Address + `)(?::[0-9]+)?`
+	tag             = `[\w][\w.-]{0,127}`
+	digest          = `[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}`
+	remoteName      = pathComponent + `(?:/` + pathComponent + `)*`
+)
+
+var (
+	domainRE = regexp.MustCompile(`^` + domainAndPort + `$`)
+	pathRE   = regexp.MustCompile(`^` + remoteName + `$`)
+	tagRE    = regexp.MustCompile(`^` + tag + `$`)
+	digestRE = regexp.MustCompile(`^` + digest + `$`)
+)
+
+var (
+	// ErrInvalidFormat is returned when a reference can't be parsed.
+	ErrInvalidFormat = errors.New("invalid reference format")
+
+	// ErrNameEmpty is returned for references without a name.
+	ErrNameEmpty = errors.New("repository name must have at least one component")
+
+	// ErrInvalidTag is returned when the tag in a reference is not valid.
+	ErrInvalidTag = errors.New("invalid tag format")
+
+	// ErrInvalidDigest is returned when the digest in a reference is not valid.
+	ErrInvalidDigest = errors.New("invalid digest format")
+)
+
+// Reference is a parsed container image reference, e.g.
+// quay.io:443/my-org/my-image:v1.0.0@sha256:...
+type Reference struct {
+	Domain string
+	Path   string
+	Tag    string
+	Digest string
+}
+
+// Parse parses an image reference, the domain is only populated if the first
+// component of the name looks like a host i.e. it has a "." or ":" or is
+// "localhost".
+func Parse(s string) (*Reference, error) {
+	if s == "" {
+		return nil, ErrNameEmpty
+	}
+	ref := &Reference{}
+	name := s
+	if i := strings.IndexRune(name, '@'); i != -1 {
+		name, ref.Digest = name[:i], name[i+1:]
+		if err := ValidateDigest(ref.Digest); err != nil {
+			return nil, err
+		}
+	}
+	if i := strings.LastIndexByte(name, ':'); i > strings.LastIndexByte(name, '/') {
+		name, ref.Tag = name[:i], name[i+1:]
+		if err := ValidateTag(ref.Tag); err != nil {
+			return nil, err
+		}
+	}
+	if name == "" {
+		return nil, ErrNameEmpty
+	}
+	ref.Domain, ref.Path = splitDomain(name)
+	if ref.Domain != "" && !domainRE.MatchString(ref.Domain) {
+		return nil, fmt.Errorf("%w: invalid domain in %q", ErrInvalidFormat, s)
+	}
+	if !pathRE.MatchString(ref.Path) {
+		return nil, fmt.Errorf("%w: invalid repository name in %q", ErrInvalidFormat, s)
+	}
+	return ref, nil
+}
+
+// ParseName parses a repository name, without a tag or digest.
+func ParseName(s string) (*Reference, error) {
+	ref, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if ref.Tag != "" || ref.Digest != "" {
+		return nil, fmt.Errorf("%w: %q is not a repository name", ErrInvalidFormat, s)
+	}
+	return ref, nil
+}
+
+// ValidateTag returns an error if the provided string is not a valid tag.
+func ValidateTag(s string) error {
+	if !tagRE.MatchString(s) {
+		return fmt.Errorf("%w: %q", ErrInvalidTag, s)
+	}
+	return nil
+}
+
+// ValidateDigest returns an error if the provided string is not a valid
+// digest e.g. sha256:...
+func ValidateDigest(s string) error {
+	if !digestRE.MatchString(s) {
+		return fmt.Errorf("%w: %q", ErrInvalidDigest, s)
+	}
+	return nil
+}
+
+// Name returns the domain and path of the reference, without the tag or
+// digest.
+func (r Reference) Name() string {
+	if r.Domain == "" {
+		return r.Path
+	}
+	return r.Domain + "/" + r.Path
+}
+
+// String returns the reference in its canonical text form.
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s = s + ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s = s + "@" + r.Digest
+	}
+	return s
+}
+
+func splitDomain(name string) (string, string) {
+	i := strings.IndexRune(name, '/')
+	if i == -1 {
+		return "", name
+	}
+	first := name[:i]
+	if !strings.ContainsAny(first, ".:") && first != "localhost" {
+		return "", name
+	}
+	return first, name[i+1:]
+}
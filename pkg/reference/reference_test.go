@@ -0,0 +1,98 @@
+package reference
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testDigest = "sha256:6ec128e26cd5da4f1f2f6d2bd0e0a4c6b8f1e9f3e5a9e7d4b1c2a3f4e5d6c7b8"
+
+func TestParse(t *testing.T) {
+	parseTests := []struct {
+		ref     string
+		want    *Reference
+		wantErr error
+	}{
+		{"my-image", &Reference{Path: "my-image"}, nil},
+		{"my-org/my-image:v1.0.0", &Reference{Path: "my-org/my-image", Tag: "v1.0.0"}, nil},
+		{"quay.io/my-org/my-image:latest", &Reference{Domain: "quay.io", Path: "my-org/my-image", Tag: "latest"}, nil},
+		{"localhost/my-image", &Reference{Domain: "localhost", Path: "my-image"}, nil},
+		{"registry.example.com:5000/team/service-a:v1", &Reference{Domain: "registry.example.com:5000", Path: "team/service-a", Tag: "v1"}, nil},
+		{"registry.example.com:5000/team/service-a", &Reference{Domain: "registry.example.com:5000", Path: "team/service-a"}, nil},
+		{"[::1]:5000/my-image:v1", &Reference{Domain: "[::1]:5000", Path: "my-image", Tag: "v1"}, nil},
+		{"gcr.io/my-project/my-image@" + testDigest, &Reference{Domain: "gcr.io", Path: "my-project/my-image", Digest: testDigest}, nil},
+		{"gcr.io/my-project/my-image:v1@" + testDigest, &Reference{Domain: "gcr.io", Path: "my-project/my-image", Tag: "v1", Digest: testDigest}, nil},
+		{"", nil, ErrNameEmpty},
+		{":v1", nil, ErrNameEmpty},
+		{"My-Image:v1", nil, ErrInvalidFormat},
+		{"my-image:", nil, ErrInvalidTag},
+		{"my-image:-v1", nil, ErrInvalidTag},
+		{"my-image@sha256:1234", nil, ErrInvalidDigest},
+		{"my-image@sha256:" + testDigest[7:] + "@sha256:1", nil, ErrInvalidDigest},
+		{"-bad.example.com/my-image", nil, ErrInvalidFormat},
+		{"quay.io/my-org//my-image", nil, ErrInvalidFormat},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(tt.ref, func(t *testing.T) {
+			ref, err := Parse(tt.ref)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, ref); diff != "" {
+				t.Fatalf("Parse(%q) failed:\n%s", tt.ref, diff)
+			}
+		})
+	}
+}
+
+func TestParseName(t *testing.T) {
+	if _, err := ParseName("quay.io/my-org/my-image"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ParseName("quay.io/my-org/my-image:latest")
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("got error %v, want %v", err, ErrInvalidFormat)
+	}
+}
+
+func TestReferenceString(t *testing.T) {
+	stringTests := []string{
+		"my-image",
+		"quay.io/my-org/my-image:v1.0.0",
+		"registry.example.com:5000/team/service-a:v1@" + testDigest,
+		"gcr.io/my-project/my-image@" + testDigest,
+	}
+
+	for _, s := range stringTests {
+		ref, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ref.String(); got != s {
+			t.Errorf("String() got %s, want %s", got, s)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	f.Add("quay.io/my-org/my-image:v1.0.0")
+	f.Add("registry.example.com:5000/team/service-a:v1@" + testDigest)
+	f.Add("[::1]:5000/my-image")
+	f.Fuzz(func(t *testing.T, s string) {
+		ref, err := Parse(s)
+		if err != nil {
+			return
+		}
+		again, err := Parse(ref.String())
+		if err != nil {
+			t.Fatalf("failed to reparse %q from %q: %s", ref.String(), s, err)
+		}
+		if diff := cmp.Diff(ref, again); diff != "" {
+			t.Fatalf("reparsing %q changed the reference:\n%s", s, diff)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+)
+
+// transientMessages identify errors from Git services that will probably
+// succeed if retried, when the response status isn't known.
+//
+// The go-scm GitHub driver returns the status text as the error.
+var transientMessages = []string{
+	http.StatusText(http.StatusTooManyRequests),
+	http.StatusText(http.StatusInternalServerError),
+	http.StatusText(http.StatusBadGateway),
+	http.StatusText(http.StatusServiceUnavailable),
+	http.StatusText(http.StatusGatewayTimeout),
+}
+
+// staleCommitMessage is in GitLab's response when a file is updated with a
+// stale last commit ID.
+const staleCommitMessage = "has changed since you started editing it"
+
+// Error is a classified error from a Git service.
+type Error struct {
+	Err error
+
+	// Transient is true if the operation is likely to succeed if it's
+	// retried.
+	Transient bool
+
+	// RetryAfter is the delay requested by the Git service before retrying,
+	// if it provided one.
+	RetryAfter time.Duration
+
+	// Conflict is true if the Git service rejected a change because it was
+	// based on a stale version e.g. a file was changed after it was fetched.
+	Conflict bool
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient returns true if the error is or wraps a transient Error.
+func IsTransient(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Transient
+}
+
+// IsConflict returns true if the error is or wraps an Error for a conflicting
+// change.
+func IsConflict(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Conflict
+}
+
+// RetryAfter returns the delay requested by the Git service before retrying,
+// or zero if the error doesn't have one.
+func RetryAfter(err error) time.Duration {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.RetryAfter
+	}
+	return 0
+}
+
+// Classify classifies the error from an operation, using the last error
+// response from the Git service if it's available.
+//
+// Errors that have already been classified are returned unchanged.
+func Classify(err error, res *Response) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	if res != nil {
+		return classifyResponse(err, res, time.Now())
+	}
+	return &Error{Err: err, Transient: isTransientError(err)}
+}
+
+func classifyResponse(err error, res *Response, now time.Time) error {
+	retryAfter := parseRetryAfter(res.Header.Get("Retry-After"), now)
+	switch {
+	case res.Status == http.StatusTooManyRequests:
+		return &Error{Err: err, Transient: true, RetryAfter: retryAfter}
+	case res.Status == http.StatusForbidden && res.Header.Get("X-RateLimit-Remaining") == "0":
+		// GitHub's primary rate limit.
+		return &Error{Err: err, Transient: true, RetryAfter: rateLimitReset(res.Header.Get("X-RateLimit-Reset"), now)}
+	case res.Status == http.StatusForbidden && retryAfter > 0:
+		// GitHub's secondary rate limits.
+		return &Error{Err: err, Transient: true, RetryAfter: retryAfter}
+	case res.Status >= http.StatusInternalServerError:
+		return &Error{Err: err, Transient: true, RetryAfter: retryAfter}
+	case res.Status == http.StatusConflict:
+		// GitHub responds with 409 when a file is updated with a stale SHA.
+		return &Error{Err: err, Conflict: true}
+	case (res.Status == http.StatusBadRequest || res.Status == http.StatusUnprocessableEntity) && bytes.Contains(res.Body, []byte(staleCommitMessage)):
+		// GitLab rejects updates with a stale last commit ID, other
+		// validation failures won't succeed if they're retried.
+		return &Error{Err: err, Conflict: true}
+	}
+	return &Error{Err: err}
+}
+
+func isTransientError(err error) bool {
+	if errors.Is(err, scm.ErrNotFound) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return true
+	}
+	for _, m := range transientMessages {
+		if strings.Contains(err.Error(), m) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRetryAfter parses the Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func parseRetryAfter(s string, now time.Time) time.Duration {
+	if s == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(s); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(s); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// rateLimitReset parses GitHub's X-RateLimit-Reset header which is the time
+// the rate limit resets in seconds since the epoch.
+func rateLimitReset(s string, now time.Time) time.Duration {
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	if reset := time.Unix(secs, 0); reset.After(now) {
+		return reset.Sub(now)
+	}
+	return 0
+}
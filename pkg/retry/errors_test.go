@@ -0,0 +1,98 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+)
+
+func TestClassify(t *testing.T) {
+	now := time.Date(2023, time.April, 3, 10, 0, 0, 0, time.UTC)
+	testErr := errors.New("test error")
+
+	classifyTests := []struct {
+		name           string
+		err            error
+		res            *Response
+		wantTransient  bool
+		wantRetryAfter time.Duration
+		wantConflict   bool
+	}{
+		{"unknown error", testErr, nil, false, 0, false},
+		{"not found", scm.ErrNotFound, nil, false, 0, false},
+		{"cancelled", context.Canceled, nil, false, 0, false},
+		{"deadline exceeded", fmt.Errorf("failed: %w", context.DeadlineExceeded), nil, true, 0, false},
+		{"bad gateway message", errors.New("failed to update file: Bad Gateway"), nil, true, 0, false},
+		{"rate limited message", errors.New(http.StatusText(http.StatusTooManyRequests)), nil, true, 0, false},
+		{"bad request", testErr, response(http.StatusBadRequest, nil), false, 0, false},
+		{"unauthorized", testErr, response(http.StatusUnauthorized, nil), false, 0, false},
+		{"server error", testErr, response(http.StatusServiceUnavailable, nil), true, 0, false},
+		{"server error with Retry-After", testErr, response(http.StatusServiceUnavailable, map[string]string{"Retry-After": "10"}), true, 10 * time.Second, false},
+		{"too many requests", testErr, response(http.StatusTooManyRequests, map[string]string{"Retry-After": now.Add(time.Minute).Format(http.TimeFormat)}), true, time.Minute, false},
+		{"GitHub rate limit", testErr, response(http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": fmt.Sprint(now.Add(30 * time.Second).Unix())}), true, 30 * time.Second, false},
+		{"GitHub secondary rate limit", testErr, response(http.StatusForbidden, map[string]string{"Retry-After": "60"}), true, time.Minute, false},
+		{"forbidden", testErr, response(http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "10"}), false, 0, false},
+		{"conflict", testErr, response(http.StatusConflict, nil), false, 0, true},
+		{"unprocessable", testErr, response(http.StatusUnprocessableEntity, nil), false, 0, false},
+		{"GitHub reference already exists", testErr, responseWithBody(http.StatusUnprocessableEntity, `{"message":"Reference already exists"}`), false, 0, false},
+		{"GitLab stale commit", testErr, responseWithBody(http.StatusBadRequest, `{"message":"You are attempting to update a file that has changed since you started editing it."}`), false, 0, true},
+		{"GitLab stale commit unprocessable", testErr, responseWithBody(http.StatusUnprocessableEntity, `{"message":"You are attempting to update a file that has changed since you started editing it."}`), false, 0, true},
+	}
+
+	for _, tt := range classifyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.res != nil {
+				err = classifyResponse(tt.err, tt.res, now)
+			} else {
+				err = Classify(tt.err, nil)
+			}
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want it to wrap %v", err, tt.err)
+			}
+			if IsTransient(err) != tt.wantTransient {
+				t.Fatalf("got transient %v, want %v", IsTransient(err), tt.wantTransient)
+			}
+			if d := RetryAfter(err); d != tt.wantRetryAfter {
+				t.Fatalf("got RetryAfter %v, want %v", d, tt.wantRetryAfter)
+			}
+			if IsConflict(err) != tt.wantConflict {
+				t.Fatalf("got conflict %v, want %v", IsConflict(err), tt.wantConflict)
+			}
+		})
+	}
+}
+
+func TestClassifyWithClassifiedError(t *testing.T) {
+	classified := &Error{Err: errors.New("test error"), Transient: true}
+
+	if err := Classify(fmt.Errorf("wrapped: %w", classified), response(http.StatusBadRequest, nil)); !IsTransient(err) {
+		t.Fatalf("got %v, want the error to remain transient", err)
+	}
+}
+
+func TestClassifyWithNoError(t *testing.T) {
+	if err := Classify(nil, response(http.StatusBadRequest, nil)); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
+
+func responseWithBody(status int, body string) *Response {
+	res := response(status, nil)
+	res.Body = []byte(body)
+	return res
+}
+
+func response(status int, headers map[string]string) *Response {
+	h := http.Header{}
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+	return &Response{Status: status, Header: h}
+}
@@ -0,0 +1,65 @@
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Backoff configures retrying transient errors with jittered exponential
+// backoff.
+type Backoff struct {
+	// Attempts is the maximum number of attempts, including the first.
+	Attempts int
+
+	// Initial is the delay before the first retry, which doubles for each
+	// subsequent retry.
+	Initial time.Duration
+
+	// Max is the maximum delay, if the Git service asks for a longer delay,
+	// the error is returned rather than waiting.
+	Max time.Duration
+}
+
+// DefaultBackoff is the default configuration for retrying transient errors.
+var DefaultBackoff = Backoff{Attempts: 5, Initial: 500 * time.Millisecond, Max: time.Minute}
+
+// Do calls f until it succeeds, or returns a permanent error, or the attempts
+// are exhausted, waiting between attempts.
+//
+// The returned error is classified as an *Error.
+func (b Backoff) Do(ctx context.Context, f func(ctx context.Context) error) error {
+	delay := b.Initial
+	for attempt := 1; ; attempt++ {
+		rctx, rec := withRecorder(ctx)
+		err := Classify(f(rctx), rec.last())
+		if err == nil || !IsTransient(err) || attempt >= b.Attempts {
+			return err
+		}
+		wait := jitter(delay)
+		if d := RetryAfter(err); d > 0 {
+			wait = d
+		}
+		if wait > b.Max {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay = delay * 2
+		if delay > b.Max {
+			delay = b.Max
+		}
+	}
+}
+
+// jitter returns a random duration between half the delay and the delay.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
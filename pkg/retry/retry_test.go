@@ -0,0 +1,195 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBackoffDo(t *testing.T) {
+	transientErr := errors.New(http.StatusText(http.StatusBadGateway))
+	permanentErr := errors.New("permanent")
+
+	doTests := []struct {
+		name          string
+		errs          []error
+		wantErr       error
+		wantTransient bool
+		wantCalls     int
+	}{
+		{"success", nil, nil, false, 1},
+		{"transient then success", []error{transientErr, transientErr}, nil, false, 3},
+		{"permanent", []error{permanentErr}, permanentErr, false, 1},
+		{"transient then permanent", []error{transientErr, permanentErr}, permanentErr, false, 2},
+		{"attempts exhausted", []error{transientErr, transientErr, transientErr, transientErr}, transientErr, true, 3},
+	}
+
+	for _, tt := range doTests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Second}
+			calls := 0
+
+			err := b.Do(context.Background(), func(ctx context.Context) error {
+				calls++
+				if calls > len(tt.errs) {
+					return nil
+				}
+				return tt.errs[calls-1]
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if IsTransient(err) != tt.wantTransient {
+				t.Fatalf("got transient %v, want %v", IsTransient(err), tt.wantTransient)
+			}
+			if calls != tt.wantCalls {
+				t.Fatalf("got %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestBackoffDoWithResponses(t *testing.T) {
+	responses := []func(w http.ResponseWriter){
+		func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		},
+		func(w http.ResponseWriter) { w.WriteHeader(http.StatusForbidden) },
+	}
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		responses[calls](w)
+		calls++
+	}))
+	defer ts.Close()
+	client := &http.Client{Transport: NewTransport(nil)}
+	b := Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Second}
+
+	err := b.Do(context.Background(), func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		if err != nil {
+			return err
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		return errors.New(http.StatusText(res.StatusCode))
+	})
+
+	if calls != 2 {
+		t.Fatalf("got %d calls, want 2", calls)
+	}
+	if IsTransient(err) {
+		t.Fatalf("got transient error %v, want a permanent error", err)
+	}
+}
+
+func TestBackoffDoWithClientErrors(t *testing.T) {
+	clientErrorTests := []struct {
+		name         string
+		status       int
+		body         string
+		wantConflict bool
+	}{
+		{"GitHub validation failure", http.StatusUnprocessableEntity, `{"message":"Reference already exists"}`, false},
+		{"GitHub conflict", http.StatusConflict, `{"message":"is at 1234 but expected 5678"}`, true},
+		{"GitLab stale commit", http.StatusBadRequest, `{"message":"You are attempting to update a file that has changed since you started editing it."}`, true},
+	}
+
+	for _, tt := range clientErrorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer ts.Close()
+			client := &http.Client{Transport: NewTransport(nil)}
+			b := Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Second}
+
+			var body []byte
+			err := b.Do(context.Background(), func(ctx context.Context) error {
+				req, err := http.NewRequestWithContext(ctx, http.MethodPut, ts.URL, nil)
+				if err != nil {
+					return err
+				}
+				res, err := client.Do(req)
+				if err != nil {
+					return err
+				}
+				defer res.Body.Close()
+				if body, err = io.ReadAll(res.Body); err != nil {
+					return err
+				}
+				return errors.New(http.StatusText(res.StatusCode))
+			})
+
+			if calls != 1 {
+				t.Fatalf("got %d calls, want 1", calls)
+			}
+			if IsTransient(err) {
+				t.Fatalf("got transient error %v, want a permanent error", err)
+			}
+			if IsConflict(err) != tt.wantConflict {
+				t.Fatalf("got conflict %v, want %v", IsConflict(err), tt.wantConflict)
+			}
+			if string(body) != tt.body {
+				t.Fatalf("got body %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestBackoffDoWithLongRetryAfter(t *testing.T) {
+	b := Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Second}
+	calls := 0
+
+	err := b.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return &Error{Err: errors.New("rate limited"), Transient: true, RetryAfter: time.Hour}
+	})
+
+	if calls != 1 {
+		t.Fatalf("got %d calls, want 1", calls)
+	}
+	if d := RetryAfter(err); d != time.Hour {
+		t.Fatalf("got RetryAfter %v, want %v", d, time.Hour)
+	}
+}
+
+func TestBackoffDoWithCancelledContext(t *testing.T) {
+	b := Backoff{Attempts: 3, Initial: time.Hour, Max: 2 * time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	err := b.Do(ctx, func(ctx context.Context) error {
+		calls++
+		cancel()
+		return errors.New(http.StatusText(http.StatusBadGateway))
+	})
+
+	if calls != 1 {
+		t.Fatalf("got %d calls, want 1", calls)
+	}
+	if !IsTransient(err) {
+		t.Fatalf("got %v, want a transient error", err)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := jitter(time.Second); d < 500*time.Millisecond || d > time.Second {
+			t.Fatalf("got %v, want between 500ms and 1s", d)
+		}
+	}
+}
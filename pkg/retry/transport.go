@@ -0,0 +1,96 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// maxRecordedBody is the maximum size of the start of the body that is
+// recorded from error responses.
+const maxRecordedBody = 4096
+
+type recorderKey struct{}
+
+// Response is the status and headers of an error response from a Git
+// service.
+type Response struct {
+	Status int
+	Header http.Header
+
+	// Body is the start of the body of client error responses, which
+	// identifies the cause of some errors.
+	Body []byte
+}
+
+// recorder records the last error response for requests made with a
+// context.
+type recorder struct {
+	mu  sync.Mutex
+	res *Response
+}
+
+func (r *recorder) record(res *http.Response, body []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.res = &Response{Status: res.StatusCode, Header: res.Header.Clone(), Body: body}
+}
+
+func (r *recorder) last() *Response {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.res
+}
+
+func withRecorder(ctx context.Context) (context.Context, *recorder) {
+	r := &recorder{}
+	return context.WithValue(ctx, recorderKey{}, r), r
+}
+
+// Transport is an http.RoundTripper that records error responses, so that
+// errors can be classified with the response status and headers, which the
+// go-scm drivers don't return.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// NewTransport wraps the base RoundTripper in a Transport, if the base is
+// nil, http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+// RoundTrip is an implementation of the http.RoundTripper interface.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+	if r, ok := req.Context().Value(recorderKey{}).(*recorder); ok && res.StatusCode >= http.StatusBadRequest {
+		var body []byte
+		if res.StatusCode < http.StatusInternalServerError {
+			body = peekBody(res)
+		}
+		r.record(res, body)
+	}
+	return res, nil
+}
+
+// peekBody returns the start of the body of the response, and replaces the
+// body so that it can still be read in full.
+func peekBody(res *http.Response) []byte {
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxRecordedBody))
+	res.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), res.Body), res.Body}
+	if err != nil {
+		return nil
+	}
+	return body
+}
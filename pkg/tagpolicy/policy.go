@@ -0,0 +1,126 @@
+package tagpolicy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gitops-tools/pkg/updater"
+	"github.com/tidwall/gjson"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gitops-tools/image-updater/pkg/config"
+	"github.com/gitops-tools/image-updater/pkg/reference"
+)
+
+var (
+	// ErrNotAllowed is wrapped by errors returned when a tag is not allowed
+	// by a policy.
+	ErrNotAllowed = errors.New("tag not allowed by policy")
+
+	// ErrDowngrade is wrapped by errors returned when a tag is older than the
+	// current tag.
+	ErrDowngrade = errors.New("tag is older than the current tag")
+)
+
+// Policy is the config.TagPolicy of a repository.
+type Policy struct {
+	constraints    *semver.Constraints
+	prerelease     bool
+	neverDowngrade bool
+}
+
+// ForRepository returns the policy for the repository, using the semver
+// constraint that was compiled when the configuration was validated, or nil
+// if the repository has no policy.
+func ForRepository(r *config.Repository) (*Policy, error) {
+	if r.TagPolicy == nil {
+		return nil, nil
+	}
+	c, err := r.SemverConstraints()
+	if err != nil {
+		return nil, err
+	}
+	return &Policy{constraints: c, prerelease: r.TagPolicy.Prerelease, neverDowngrade: r.TagPolicy.NeverDowngrade}, nil
+}
+
+// Allows returns an error wrapping ErrNotAllowed if the tag is not allowed
+// by the policy.
+//
+// Tags that aren't semantic versions are only allowed if there's no semver
+// constraint.
+//
+// Prerelease versions are checked against the constraint using the release
+// version, i.e. v1.2.0-rc1 satisfies ">=1.2".
+func (p *Policy) Allows(tag string) error {
+	v, err := semver.NewVersion(tag)
+	if err != nil {
+		if p.constraints != nil {
+			return fmt.Errorf("%w: %q is not a semantic version", ErrNotAllowed, tag)
+		}
+		return nil
+	}
+	if v.Prerelease() != "" {
+		if !p.prerelease {
+			return fmt.Errorf("%w: %q is a prerelease", ErrNotAllowed, tag)
+		}
+		release, err := v.SetPrerelease("")
+		if err != nil {
+			return err
+		}
+		v = &release
+	}
+	if p.constraints != nil && !p.constraints.Check(v) {
+		return fmt.Errorf("%w: %q does not satisfy %q", ErrNotAllowed, tag, p.constraints)
+	}
+	return nil
+}
+
+// ContentUpdater wraps the ContentUpdater, and if the policy never downgrades,
+// returns an error wrapping ErrDowngrade without updating the body if the
+// image at the key in the YAML body has a newer tag than the new image.
+//
+// If either tag is not a semantic version, the body is updated.
+func (p *Policy) ContentUpdater(key, newURL string, f updater.ContentUpdater) updater.ContentUpdater {
+	if !p.neverDowngrade {
+		return f
+	}
+	return func(b []byte) ([]byte, error) {
+		current, err := currentValue(b, key)
+		if err != nil {
+			return nil, err
+		}
+		if isDowngrade(current, newURL) {
+			return nil, fmt.Errorf("%w: %s is older than %s", ErrDowngrade, newURL, current)
+		}
+		return f(b)
+	}
+}
+
+func currentValue(b []byte, key string) (string, error) {
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	return gjson.GetBytes(j, key).String(), nil
+}
+
+func isDowngrade(currentURL, newURL string) bool {
+	current, err := versionOf(currentURL)
+	if err != nil {
+		return false
+	}
+	updated, err := versionOf(newURL)
+	if err != nil {
+		return false
+	}
+	return updated.LessThan(current)
+}
+
+func versionOf(image string) (*semver.Version, error) {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return nil, err
+	}
+	return semver.NewVersion(ref.Tag)
+}
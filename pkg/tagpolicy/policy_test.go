@@ -0,0 +1,148 @@
+package tagpolicy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gitops-tools/pkg/updater"
+
+	"github.com/gitops-tools/image-updater/pkg/config"
+	"github.com/gitops-tools/image-updater/test"
+)
+
+func TestForRepository(t *testing.T) {
+	r := validatedRepository(t, &config.TagPolicy{Semver: ">=1.2 <2.0"})
+
+	p, err := ForRepository(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.SemverConstraints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.constraints != c {
+		t.Fatal("ForRepository() didn't use the compiled constraint")
+	}
+}
+
+func TestForRepositoryWithInvalidConstraint(t *testing.T) {
+	_, err := ForRepository(&config.Repository{TagPolicy: &config.TagPolicy{Semver: "not a constraint"}})
+
+	if !test.MatchError(t, `failed to parse semver constraint "not a constraint"`, err) {
+		t.Fatalf("got error %v", err)
+	}
+}
+
+func TestForRepositoryWithoutPolicy(t *testing.T) {
+	p, err := ForRepository(&config.Repository{Name: "testing/repo-image"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != nil {
+		t.Fatalf("got policy %#v, want nil", p)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	allowTests := []struct {
+		name    string
+		policy  config.TagPolicy
+		tag     string
+		wantErr string
+	}{
+		{"no constraint", config.TagPolicy{}, "v1.2.0", ""},
+		{"no constraint with non-semver tag", config.TagPolicy{}, "latest", ""},
+		{"satisfies constraint", config.TagPolicy{Semver: ">=1.2 <2.0"}, "v1.2.0", ""},
+		{"satisfies constraint without prefix", config.TagPolicy{Semver: ">=1.2 <2.0"}, "1.9.9", ""},
+		{"below constraint", config.TagPolicy{Semver: ">=1.2 <2.0"}, "v1.1.9", `"v1.1.9" does not satisfy ">=1.2 <2.0"`},
+		{"above constraint", config.TagPolicy{Semver: ">=1.2 <2.0"}, "v2.0.0", `"v2.0.0" does not satisfy`},
+		{"non-semver tag with constraint", config.TagPolicy{Semver: ">=1.2"}, "latest", `"latest" is not a semantic version`},
+		{"excluded prerelease", config.TagPolicy{}, "v1.2.0-rc1", `"v1.2.0-rc1" is a prerelease`},
+		{"excluded prerelease with constraint", config.TagPolicy{Semver: ">=1.2"}, "v1.2.0-rc1", `"v1.2.0-rc1" is a prerelease`},
+		{"included prerelease", config.TagPolicy{Prerelease: true}, "v1.2.0-rc1", ""},
+		{"included prerelease with constraint", config.TagPolicy{Semver: ">=1.2", Prerelease: true}, "v1.2.0-rc1", ""},
+		{"included prerelease outside constraint", config.TagPolicy{Semver: ">=1.2", Prerelease: true}, "v1.1.0-rc1", "does not satisfy"},
+	}
+
+	for _, tt := range allowTests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ForRepository(validatedRepository(t, &tt.policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = p.Allows(tt.tag)
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrNotAllowed) {
+				t.Fatalf("got error %#v, want it to wrap ErrNotAllowed", err)
+			}
+		})
+	}
+}
+
+func TestContentUpdater(t *testing.T) {
+	updateTests := []struct {
+		name           string
+		neverDowngrade bool
+		current        string
+		newURL         string
+		want           string
+		wantErr        string
+	}{
+		{"upgrade", true, "quay.io/org/repo:v1.2.0", "quay.io/org/repo:v1.2.1", "quay.io/org/repo:v1.2.1", ""},
+		{"same version", true, "quay.io/org/repo:v1.2.0", "quay.io/org/repo:v1.2.0", "quay.io/org/repo:v1.2.0", ""},
+		{"downgrade", true, "quay.io/org/repo:v1.2.0", "quay.io/org/repo:v1.1.9", "", "quay.io/org/repo:v1.1.9 is older than quay.io/org/repo:v1.2.0"},
+		{"downgrade to prerelease", true, "quay.io/org/repo:v1.2.0", "quay.io/org/repo:v1.2.0-rc1", "", "is older than"},
+		{"downgrade allowed", false, "quay.io/org/repo:v1.2.0", "quay.io/org/repo:v1.1.9", "quay.io/org/repo:v1.1.9", ""},
+		{"current is not semver", true, "quay.io/org/repo:latest", "quay.io/org/repo:v1.1.9", "quay.io/org/repo:v1.1.9", ""},
+		{"new is not semver", true, "quay.io/org/repo:v1.2.0", "quay.io/org/repo:latest", "quay.io/org/repo:latest", ""},
+		{"no current value", true, "", "quay.io/org/repo:v1.1.9", "quay.io/org/repo:v1.1.9", ""},
+	}
+
+	for _, tt := range updateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ForRepository(validatedRepository(t, &config.TagPolicy{NeverDowngrade: tt.neverDowngrade}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := p.ContentUpdater("test.image", tt.newURL, updater.UpdateYAML("test.image", tt.newURL))
+
+			b, err := f([]byte("test:\n  image: " + tt.current + "\n"))
+			if !test.MatchError(t, tt.wantErr, err) {
+				t.Fatalf("got error %v, want %s", err, tt.wantErr)
+			}
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrDowngrade) {
+					t.Fatalf("got error %#v, want it to wrap ErrDowngrade", err)
+				}
+				return
+			}
+			if s, want := string(b), "test:\n  image: "+tt.want+"\n"; s != want {
+				t.Fatalf("got %q, want %q", s, want)
+			}
+		})
+	}
+}
+
+// validatedRepository returns a repository with the policy, that has been
+// validated, so that the semver constraint is compiled.
+func validatedRepository(t *testing.T, p *config.TagPolicy) *config.Repository {
+	t.Helper()
+	cfg := &config.RepoConfiguration{Repositories: []*config.Repository{
+		{
+			Name:         "testing/repo-image",
+			SourceRepo:   "testing/testing",
+			SourceBranch: "main",
+			FilePath:     "test/file.yaml",
+			UpdateKey:    "spec.image",
+			TagPolicy:    p,
+		},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg.Repositories[0]
+}
@@ -0,0 +1,40 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/gitops-tools/image-updater/pkg/hooks"
+	"github.com/gitops-tools/image-updater/pkg/reference"
+)
+
+// FuzzParser fuzzes a parser with payloads based on the seeds.
+//
+// It fails if the parser panics, returns an error that is not a
+// hooks.ParseError, or returns PushEvents that don't have a valid image URL.
+func FuzzParser(f *testing.F, parser hooks.PushEventParser, seeds ...[]byte) {
+	f.Helper()
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		events, err := parser(payload)
+		if err != nil {
+			if !hooks.IsParseError(err) {
+				t.Fatalf("parser returned an untyped error: %#v", err)
+			}
+			return
+		}
+		for _, ev := range events {
+			ref, err := reference.Parse(ev.PushedImageURL())
+			if err != nil {
+				t.Fatalf("parser returned an invalid image URL %q: %s", ev.PushedImageURL(), err)
+			}
+			if ref.Tag != ev.EventTag() {
+				t.Fatalf("image URL %q does not have tag %q", ev.PushedImageURL(), ev.EventTag())
+			}
+			if ev.EventRepository() == "" {
+				t.Fatalf("parser returned an event with no repository for %q", ev.PushedImageURL())
+			}
+		}
+	})
+}